@@ -1,29 +1,54 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
-	"unicode"
+	"strconv"
+	"time"
+
+	"ZenDB/zendb"
 )
 
 // Constants representing API paths
 const (
-	SetPath = "/set"
-	GetPath = "/get"
-	DelPath = "/del"
-	Key     = "key"
+	SetPath   = "/set"
+	GetPath   = "/get"
+	DelPath   = "/del"
+	BatchPath = "/batch"
+	TxnPath   = "/txn"
+	KVPath    = "/kv"
+	Key       = "key"
+)
+
+// Constants for the encodings of the keys and values of the requests and responses.
+const (
+	EncodingQuery  = "encoding"
+	EncodingBase64 = "base64"
+	OctetStream    = "application/octet-stream"
 )
 
 // Constants representing HTTP response status codes
 const (
-	StatusOK               = http.StatusOK
-	StatusMethodNotAllowed = http.StatusMethodNotAllowed
-	StatusBadRequest       = http.StatusBadRequest
+	StatusOK                 = http.StatusOK
+	StatusMethodNotAllowed   = http.StatusMethodNotAllowed
+	StatusBadRequest         = http.StatusBadRequest
+	StatusNotFound           = http.StatusNotFound
+	StatusConflict           = http.StatusConflict
+	StatusGone               = http.StatusGone
+	StatusPreconditionFailed = http.StatusPreconditionFailed
+	StatusUnsupportedMedia   = http.StatusUnsupportedMediaType
+	StatusRequestTooLarge    = http.StatusRequestEntityTooLarge
+	StatusUnavailable        = http.StatusServiceUnavailable
+	StatusInternalError      = http.StatusInternalServerError
 )
 
+// RetryAfter is the number of seconds a client is asked to wait before retrying a write stalled by the database.
+const RetryAfter = "1"
+
 // Custom error messages
 var (
 	ErrSpecifyToGet = errors.New("No specified key to get")
@@ -32,18 +57,78 @@ var (
 	ErrTooManyKeys  = errors.New("Too many keys specified, request cancelled")
 	ErrInvalidKey   = errors.New("Invalid key")
 	ErrInvalidValue = errors.New("Invalid value")
+	ErrEncoding     = errors.New("Invalid encoding, it should be " + EncodingBase64 + " or left out")
+	ErrContentType  = errors.New("Invalid content type, it should be " + OctetStream)
+	ErrEmptyBatch   = errors.New("You should specify at least one operation")
+	ErrInvalidOp    = errors.New("Invalid operation, it should be set or del")
+	ErrInvalidETag  = errors.New("Invalid ETag, If-Match takes a single ETag and If-None-Match only *")
+	ErrInvalidTTL   = errors.New("Invalid ttl, it should be a positive number of seconds")
+	ErrConditionTTL = errors.New("A ttl cannot be combined with If-Match or If-None-Match")
 )
 
 type DB interface {
-	Set(key, value string) error
-	Get(key string) (string, error)
-	Del(key string) (string, error)
+	Set(key, value []byte) error
+	SetWithTTL(key, value []byte, ttl time.Duration) error
+	Get(key []byte) ([]byte, error)
+	GetWithVersion(key []byte) ([]byte, uint64, error)
+	Del(key []byte) ([]byte, error)
+	SetIfAbsent(key, value []byte) error
+	SetIfVersion(key, value []byte, version uint64) error
+	DelIfVersion(key []byte, version uint64) ([]byte, error)
+	Write(b *zendb.WriteBatch) error
+	Begin() (*zendb.Txn, error)
+	NewIterator(opts *zendb.IteratorOptions) (*zendb.Iterator, error)
+}
+
+// sizeLimiter is implemented by the databases bounding the sizes of their keys and values, as zendb does.
+type sizeLimiter interface {
+	MaxSizes() (int, int)
 }
 
 type Server struct {
-	addr string
-	port string
-	lstm DB
+	addr         string
+	port         string
+	lstm         DB
+	mux          *http.ServeMux
+	scans        *scanSessions
+	maxKeySize   int64 // Largest key accepted, the default of zendb when 0
+	maxValueSize int64 // Largest value accepted, the default of zendb when 0
+}
+
+// jsonOverhead is the room left in a JSON body for its syntax and fields besides a key and a value.
+const jsonOverhead = 4 << 10
+
+// maxBody returns the largest request body accepted by the endpoint: a value for "/kv", and for the JSON
+// endpoints a document holding a key and a value of the maximum sizes, escaped as JSON strings, which may make
+// them up to 6 times larger. The operations of a batch or a transaction share the same bound.
+func (s *Server) maxBody(path string) int64 {
+	maxKey, maxValue := s.maxKeySize, s.maxValueSize
+	if maxKey == 0 {
+		maxKey = zendb.DefaultMaxKeySize
+	}
+	if maxValue == 0 {
+		maxValue = zendb.DefaultMaxValueSize
+	}
+	if path == KVPath {
+		return maxValue
+	}
+	return 6*(maxKey+maxValue) + jsonOverhead
+}
+
+// limitBody bounds the size of the request body, so that an oversized body is rejected while it is read,
+// before the whole of it is held in memory.
+func (s *Server) limitBody(response http.ResponseWriter, request *http.Request, path string) {
+	request.Body = http.MaxBytesReader(response, request.Body, s.maxBody(path))
+}
+
+// bodyErrorStatus returns the status code reporting an error reading a request body: 413 when the body is over
+// its maximum size, 400 otherwise.
+func bodyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return StatusRequestTooLarge
+	}
+	return StatusBadRequest
 }
 
 // fullAddress returns the full address of the server.
@@ -52,11 +137,48 @@ func (s Server) fullAddress() string {
 }
 
 // writeResponse writes an HTTP response with the given status code and message.
+// A 503 asks the client to retry after RetryAfter seconds.
 func writeResponse(response *http.ResponseWriter, status int, message string) {
+	if status == StatusUnavailable {
+		(*response).Header().Set("Retry-After", RetryAfter)
+	}
 	(*response).WriteHeader(status)
 	(*response).Write([]byte(message))
 }
 
+// codec decodes the keys and values of a request, and encodes those of its response. They are taken as they are
+// by default, and as standard base64 with the "encoding=base64" query, since JSON strings only hold UTF-8 text.
+type codec struct {
+	base64 bool
+}
+
+// requestCodec returns the codec chosen by the encoding query of the request.
+func requestCodec(request *http.Request) (codec, error) {
+	switch request.URL.Query().Get(EncodingQuery) {
+	case "":
+		return codec{}, nil
+	case EncodingBase64:
+		return codec{base64: true}, nil
+	}
+	return codec{}, ErrEncoding
+}
+
+// decode decodes a key or a value of the request.
+func (c codec) decode(s string) ([]byte, error) {
+	if c.base64 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}
+
+// encode encodes a key or a value of the response.
+func (c codec) encode(b []byte) string {
+	if c.base64 {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return string(b)
+}
+
 // validate checks if the specified key is present in the URL queries and follows a specified pattern.
 func validate(queries *url.Values, pattern string) error {
 	var err error
@@ -72,50 +194,89 @@ func validate(queries *url.Values, pattern string) error {
 	if len((*queries)[Key]) != 1 {
 		return ErrTooManyKeys
 	}
-	k := (*queries)[Key][0]
-	if k == "" || !isASCII(k) {
+	if (*queries)[Key][0] == "" {
 		return ErrInvalidKey
 	}
 	return nil
 }
 
-// isASCII checks if a string contains only ASCII characters.
-func isASCII(s string) bool {
-	for _, r := range s {
-		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
-			return false
-		}
-	}
-	return true
-}
-
-// validateJSON checks if the JSON data contains a single key-value pair with valid characters.
+// validateJSON checks if the JSON data contains a single key-value pair with a key. The value may be empty.
 func validateJSON(data map[string]string) error {
 	if len(data) != 1 {
 		return ErrSpecifyToSet
 	}
-	for key, value := range data {
-		if key == "" || !isASCII(key) {
+	for key := range data {
+		if key == "" {
 			return ErrInvalidKey
 		}
-
-		if value == "" || !isASCII(value) {
-			return ErrInvalidValue
-		}
 	}
 
 	return nil
 }
 
-// handleSet handles the "/set" endpoint, setting key-value pairs in the storage.
+// decodePair decodes the key and the value of a pair with the codec.
+func decodePair(c codec, key, value string) ([]byte, []byte, error) {
+	k, err := c.decode(key)
+	if err != nil || len(k) == 0 {
+		return nil, nil, ErrInvalidKey
+	}
+	v, err := c.decode(value)
+	if err != nil {
+		return nil, nil, ErrInvalidValue
+	}
+	return k, v, nil
+}
+
+// decodeSetBody decodes the body of a "/set" request: a key-value pair, and an optional "ttl" field holding
+// a number of seconds. A "ttl" key with a string value is a pair like any other.
+func decodeSetBody(body io.Reader) (map[string]string, time.Duration, error) {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&fields); err != nil {
+		return nil, 0, err
+	}
+	var ttl time.Duration
+	if raw, ok := fields["ttl"]; ok && len(raw) > 0 && raw[0] != '"' {
+		var seconds float64
+		if err := json.Unmarshal(raw, &seconds); err != nil || seconds <= 0 {
+			return nil, 0, ErrInvalidTTL
+		}
+		ttl = time.Duration(seconds * float64(time.Second))
+		delete(fields, "ttl")
+	}
+	pairs := make(map[string]string, len(fields))
+	for k, raw := range fields {
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, 0, err
+		}
+		pairs[k] = v
+	}
+	return pairs, ttl, nil
+}
+
+// handleSet handles the "/set" endpoint, setting key-value pairs in the storage. With the "encoding=base64" query,
+// the key and the value are base64 encoded.
+// With a ttl, the key expires once that many seconds have elapsed.
+// With an If-Match header, the key is only set if its version matches the ETag, and with If-None-Match: *,
+// only if it has no value. A failed precondition is reported with 412.
 func (s *Server) handleSet(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodPost {
 		writeResponse(&response, StatusMethodNotAllowed, "Method not allowed. Only POST requests are allowed.")
 		return
 	}
-	var requestBody map[string]string
-	if err := json.NewDecoder(request.Body).Decode(&requestBody); err != nil {
-		writeResponse(&response, StatusBadRequest, "Error decoding JSON data: "+err.Error())
+	c, err := requestCodec(request)
+	if err != nil {
+		writeResponse(&response, StatusBadRequest, err.Error())
+		return
+	}
+	s.limitBody(response, request, SetPath)
+	requestBody, ttl, err := decodeSetBody(request.Body)
+	if errors.Is(err, ErrInvalidTTL) {
+		writeResponse(&response, StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeResponse(&response, bodyErrorStatus(err), "Error decoding JSON data: "+err.Error())
 		return
 	}
 	if err := validateJSON(requestBody); err != nil {
@@ -123,55 +284,357 @@ func (s *Server) handleSet(response http.ResponseWriter, request *http.Request)
 		return
 	}
 
-	for k, v := range requestBody {
-		err := s.lstm.Set(k, v)
+	for key, value := range requestBody {
+		k, v, err := decodePair(c, key, value)
 		if err != nil {
 			writeResponse(&response, StatusBadRequest, err.Error())
+			return
+		}
+		ifMatch, ifNoneMatch := request.Header.Get("If-Match"), request.Header.Get("If-None-Match")
+		switch {
+		case ttl != 0 && (ifMatch != "" || ifNoneMatch != ""):
+			err = ErrConditionTTL
+		case ttl != 0:
+			err = s.lstm.SetWithTTL(k, v, ttl)
+		default:
+			err = s.setIfMatch(request, k, v)
+		}
+		if err != nil {
+			writeResponse(&response, errorStatus(err), err.Error())
+			return
 		}
 		break
 	}
 	writeResponse(&response, StatusOK, "The key-value pair was set successfully")
 }
 
+// setIfMatch sets the key under the precondition of the request: with an If-Match header, only if its version
+// matches the ETag, and with If-None-Match: *, only if it has no value.
+func (s *Server) setIfMatch(request *http.Request, key, value []byte) error {
+	ifMatch, ifNoneMatch := request.Header.Get("If-Match"), request.Header.Get("If-None-Match")
+	switch {
+	case ifNoneMatch == "*":
+		return s.lstm.SetIfAbsent(key, value)
+	case ifNoneMatch != "":
+		return ErrInvalidETag
+	case ifMatch != "":
+		version, err := parseETag(ifMatch)
+		if err != nil {
+			return err
+		}
+		return s.lstm.SetIfVersion(key, value, version)
+	}
+	return s.lstm.Set(key, value)
+}
+
+// parseETag parses an ETag returned by "/get" into the version of its key.
+func parseETag(tag string) (uint64, error) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, ErrInvalidETag
+	}
+	version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidETag
+	}
+	return version, nil
+}
+
+// errorStatus returns the status code reporting the error: 412 for a failed precondition, 413 for a key
+// or a value over its maximum size, 503 for a write that waited past its deadline for the flush and the compaction,
+// or once the database is closed, 400 otherwise.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, zendb.ErrWriteStall), errors.Is(err, zendb.ErrClosed):
+		return StatusUnavailable
+	case errors.Is(err, zendb.ErrConditionFailed):
+		return StatusPreconditionFailed
+	case errors.Is(err, zendb.ErrKeyTooLarge), errors.Is(err, zendb.ErrValueTooLarge):
+		return StatusRequestTooLarge
+	}
+	return StatusBadRequest
+}
+
+// serverErrorStatus returns the status of an error raised by the database while serving a valid request:
+// unavailable once the database is closed, an internal error otherwise.
+func serverErrorStatus(err error) int {
+	if errors.Is(err, zendb.ErrClosed) {
+		return StatusUnavailable
+	}
+	return StatusInternalError
+}
+
+// readErrorStatus returns the status of an error raised by a read: 404 for a key that does not exist,
+// or the status of serverErrorStatus for a failure of the database.
+func readErrorStatus(err error) int {
+	if errors.Is(err, zendb.ErrKeyNotFound) || errors.Is(err, zendb.ErrKeyDeleted) {
+		return StatusNotFound
+	}
+	return serverErrorStatus(err)
+}
+
+// batchOp is an operation of a "/batch" request.
+type batchOp struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// decodeOps checks that every operation is a set or a deletion of a valid key, and decodes its key and value
+// in place with the codec.
+func decodeOps(ops []batchOp, c codec) error {
+	for i, op := range ops {
+		k, v, err := decodePair(c, op.Key, op.Value)
+		if err == nil && op.Op != "set" && op.Op != "del" {
+			err = ErrInvalidOp
+		}
+		ops[i].Key, ops[i].Value = string(k), string(v)
+		if err != nil {
+			return errors.New("Operation " + strconv.Itoa(i) + " : " + err.Error())
+		}
+	}
+	return nil
+}
+
+// handleBatch handles the "/batch" endpoint, applying a list of operations all together.
+// Every operation is validated before any of them is applied.
+func (s *Server) handleBatch(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeResponse(&response, StatusMethodNotAllowed, "Method not allowed. Only POST requests are allowed.")
+		return
+	}
+	c, err := requestCodec(request)
+	if err != nil {
+		writeResponse(&response, StatusBadRequest, err.Error())
+		return
+	}
+	s.limitBody(response, request, BatchPath)
+	var ops []batchOp
+	if err := json.NewDecoder(request.Body).Decode(&ops); err != nil {
+		writeResponse(&response, bodyErrorStatus(err), "Error decoding JSON data: "+err.Error())
+		return
+	}
+	if len(ops) == 0 {
+		writeResponse(&response, StatusBadRequest, ErrEmptyBatch.Error())
+		return
+	}
+	if err := decodeOps(ops, c); err != nil {
+		writeResponse(&response, StatusBadRequest, err.Error())
+		return
+	}
+	b := zendb.NewWriteBatch()
+	for _, op := range ops {
+		if op.Op == "set" {
+			b.Set([]byte(op.Key), []byte(op.Value))
+		} else {
+			b.Del([]byte(op.Key))
+		}
+	}
+	if err := s.lstm.Write(b); err != nil {
+		writeResponse(&response, errorStatus(err), err.Error())
+		return
+	}
+	writeResponse(&response, StatusOK, "The batch was applied successfully")
+}
+
+// txnRead is a key read by the client of a "/txn" request, with the version it read, as returned by the ETag
+// of "/get". The version of a deleted key is that of its deletion, and 0 for a key never written.
+type txnRead struct {
+	Key     string `json:"key"`
+	Version uint64 `json:"version"`
+}
+
+// txnRequest is the body of a "/txn" request.
+type txnRequest struct {
+	Reads  []txnRead `json:"reads"`
+	Writes []batchOp `json:"writes"`
+}
+
+// handleTxn handles the "/txn" endpoint, applying a write set all together only if none of the keys of the read set
+// was written since the client read them. A conflict is reported with 409.
+func (s *Server) handleTxn(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeResponse(&response, StatusMethodNotAllowed, "Method not allowed. Only POST requests are allowed.")
+		return
+	}
+	c, err := requestCodec(request)
+	if err != nil {
+		writeResponse(&response, StatusBadRequest, err.Error())
+		return
+	}
+	s.limitBody(response, request, TxnPath)
+	var body txnRequest
+	if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+		writeResponse(&response, bodyErrorStatus(err), "Error decoding JSON data: "+err.Error())
+		return
+	}
+	if err := decodeOps(body.Writes, c); err != nil {
+		writeResponse(&response, StatusBadRequest, err.Error())
+		return
+	}
+	for i, r := range body.Reads {
+		k, err := c.decode(r.Key)
+		if err != nil || len(k) == 0 {
+			writeResponse(&response, StatusBadRequest, ErrInvalidKey.Error())
+			return
+		}
+		body.Reads[i].Key = string(k)
+	}
+	txn, err := s.lstm.Begin()
+	if err != nil {
+		writeResponse(&response, serverErrorStatus(err), err.Error())
+		return
+	}
+	for _, r := range body.Reads {
+		txn.Expect([]byte(r.Key), r.Version)
+	}
+	for _, op := range body.Writes {
+		if op.Op == "set" {
+			txn.Set([]byte(op.Key), []byte(op.Value))
+		} else {
+			txn.Del([]byte(op.Key))
+		}
+	}
+	if err := txn.Commit(); errors.Is(err, zendb.ErrConflict) {
+		writeResponse(&response, StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		writeResponse(&response, errorStatus(err), err.Error())
+		return
+	}
+	writeResponse(&response, StatusOK, "The transaction was committed successfully")
+}
+
+// etag returns the entity tag of a version of a key.
+func etag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
 // helperGetDel is a helper function for handling "/get" and "/del" endpoints.
-func helperGetDel(response *http.ResponseWriter, request *http.Request, function func(string) (string, error), format string) {
+// With the "encoding=base64" query, the key of the query and the key and value of the response are base64 encoded.
+func helperGetDel(response *http.ResponseWriter, request *http.Request, function func([]byte) ([]byte, error), format string) {
 	queries := request.URL.Query()
 	if err := validate(&queries, GetPath); err != nil {
 		writeResponse(response, StatusBadRequest, err.Error())
 		return
 	}
+	c, err := requestCodec(request)
+	if err != nil {
+		writeResponse(response, StatusBadRequest, err.Error())
+		return
+	}
 	k := queries[Key][0]
-	if v, err := function(k); err != nil {
-		writeResponse(response, StatusBadRequest, k+" : "+err.Error())
+	key, err := c.decode(k)
+	if err != nil {
+		writeResponse(response, StatusBadRequest, ErrInvalidKey.Error())
+		return
+	}
+	if v, err := function(key); err != nil {
+		writeResponse(response, errorStatus(err), k+" : "+err.Error())
 	} else {
-		writeResponse(response, StatusOK, format+k+" : "+v)
+		writeResponse(response, StatusOK, format+k+" : "+c.encode(v))
 	}
 }
 
 // handleGet handles the "/get" endpoint, retrieving the value for a specified key.
 func (s *Server) handleGet(response http.ResponseWriter, request *http.Request) {
-	helperGetDel(&response, request, s.lstm.Get, "")
+	// The ETag carries the version of the key, to be sent back in the read set of "/txn". A deleted key has
+	// the version of its deletion, so that a transaction reading it as absent conflicts with a later write.
+	helperGetDel(&response, request, func(key []byte) ([]byte, error) {
+		v, version, err := s.lstm.GetWithVersion(key)
+		if err == nil || errors.Is(err, zendb.ErrKeyDeleted) || errors.Is(err, zendb.ErrKeyNotFound) {
+			response.Header().Set("ETag", etag(version))
+		}
+		return v, err
+	}, "")
 }
 
 // handleDel handles the "/del" endpoint, deleting a specified key from the storage.
+// With an If-Match header, the key is only deleted if its version matches the ETag.
 func (s *Server) handleDel(response http.ResponseWriter, request *http.Request) {
-	helperGetDel(&response, request, s.lstm.Del, "Deleted Successfully : ")
+	del := s.lstm.Del
+	if ifMatch := request.Header.Get("If-Match"); ifMatch != "" {
+		del = func(key []byte) ([]byte, error) {
+			version, err := parseETag(ifMatch)
+			if err != nil {
+				return nil, err
+			}
+			return s.lstm.DelIfVersion(key, version)
+		}
+	}
+	helperGetDel(&response, request, del, "Deleted Successfully : ")
 }
 
-// NewServer creates a new instance of the HTTP server.
-func NewServer() Server {
-	lstm, err := LstmDB()
+// handleKV handles the "/kv" endpoint, the binary form of "/set" and "/get". PUT sets the key to the request body,
+// and GET returns its value as the response body, with its version as the ETag. Both bodies are application/octet-stream,
+// and the key of the query may hold any bytes, percent-encoded. GET answers a missing key with 404, and a failure
+// of the database with 500, or 503 once closed. PUT takes the If-Match and If-None-Match headers of "/set",
+// a failed precondition being reported with 412.
+func (s *Server) handleKV(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodPut {
+		writeResponse(&response, StatusMethodNotAllowed, "Method not allowed. Only GET and PUT requests are allowed.")
+		return
+	}
+	queries := request.URL.Query()
+	if err := validate(&queries, GetPath); err != nil {
+		writeResponse(&response, StatusBadRequest, err.Error())
+		return
+	}
+	key := []byte(queries[Key][0])
+
+	if request.Method == http.MethodPut {
+		if ct := request.Header.Get("Content-Type"); ct != "" && ct != OctetStream {
+			writeResponse(&response, StatusUnsupportedMedia, ErrContentType.Error())
+			return
+		}
+		s.limitBody(response, request, KVPath)
+		value, err := io.ReadAll(request.Body)
+		if err != nil {
+			writeResponse(&response, bodyErrorStatus(err), err.Error())
+			return
+		}
+		if err := s.setIfMatch(request, key, value); err != nil {
+			writeResponse(&response, errorStatus(err), err.Error())
+			return
+		}
+		writeResponse(&response, StatusOK, "The key-value pair was set successfully")
+		return
+	}
+
+	v, version, err := s.lstm.GetWithVersion(key)
 	if err != nil {
-		log.Fatal(err)
+		writeResponse(&response, readErrorStatus(err), err.Error())
+		return
 	}
+	response.Header().Set("Content-Type", OctetStream)
+	response.Header().Set("ETag", etag(version))
+	response.WriteHeader(StatusOK)
+	response.Write(v)
+}
+
+// NewServer creates a new instance of the HTTP server on top of the given database.
+func NewServer(lstm DB) Server {
 	s := Server{
-		addr: "",
-		port: "8081",
-		lstm: lstm,
-	}
-	http.HandleFunc(SetPath, s.handleSet)
-	http.HandleFunc(GetPath, s.handleGet)
-	http.HandleFunc(DelPath, s.handleDel)
-	log.Fatal(http.ListenAndServe(s.fullAddress(), nil))
+		addr:  "",
+		port:  "8081",
+		lstm:  lstm,
+		mux:   http.NewServeMux(),
+		scans: newScanSessions(),
+	}
+	if l, ok := lstm.(sizeLimiter); ok {
+		maxKey, maxValue := l.MaxSizes()
+		s.maxKeySize, s.maxValueSize = int64(maxKey), int64(maxValue)
+	}
+	s.mux.HandleFunc(SetPath, s.handleSet)
+	s.mux.HandleFunc(GetPath, s.handleGet)
+	s.mux.HandleFunc(DelPath, s.handleDel)
+	s.mux.HandleFunc(BatchPath, s.handleBatch)
+	s.mux.HandleFunc(TxnPath, s.handleTxn)
+	s.mux.HandleFunc(ScanPath, s.handleScan)
+	s.mux.HandleFunc(KVPath, s.handleKV)
 	return s
 }
+
+// ListenAndServe starts serving the API endpoints.
+func (s Server) ListenAndServe() error {
+	return http.ListenAndServe(s.fullAddress(), s.mux)
+}
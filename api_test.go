@@ -1,35 +1,92 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
+
+	"ZenDB/zendb"
 )
 
 // Mock Lstm implementation for testing
 type mockLstm struct {
-	data map[string]string
+	data     map[string]string
+	ttl      time.Duration // TTL of the last SetWithTTL
+	writeErr error         // Returned by Set when not nil
 }
 
-func (m *mockLstm) Set(key, value string) error {
-	m.data[key] = value
+func (m *mockLstm) Set(key, value []byte) error {
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	m.data[string(key)] = string(value)
 	return nil
 }
 
-func (m *mockLstm) Get(key string) (string, error) {
-	if val, ok := m.data[key]; ok {
-		return val, nil
+func (m *mockLstm) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	m.ttl = ttl
+	return m.Set(key, value)
+}
+
+func (m *mockLstm) Get(key []byte) ([]byte, error) {
+	if val, ok := m.data[string(key)]; ok {
+		return []byte(val), nil
+	}
+	return nil, zendb.ErrKeyNotFound
+}
+
+func (m *mockLstm) GetWithVersion(key []byte) ([]byte, uint64, error) {
+	v, err := m.Get(key)
+	return v, 0, err
+}
+
+func (m *mockLstm) SetIfAbsent(key, value []byte) error {
+	if _, ok := m.data[string(key)]; ok {
+		return zendb.ErrConditionFailed
 	}
-	return "", ErrKeyNotFound
+	return m.Set(key, value)
 }
 
-func (m *mockLstm) Del(key string) (string, error) {
-	if val, ok := m.data[key]; ok {
-		delete(m.data, key)
-		return val, nil
+func (m *mockLstm) SetIfVersion(key, value []byte, version uint64) error {
+	if _, ok := m.data[string(key)]; !ok || version != 0 {
+		return zendb.ErrConditionFailed
 	}
-	return "", ErrKeyNotFound
+	return m.Set(key, value)
+}
+
+func (m *mockLstm) DelIfVersion(key []byte, version uint64) ([]byte, error) {
+	if _, ok := m.data[string(key)]; !ok || version != 0 {
+		return nil, zendb.ErrConditionFailed
+	}
+	return m.Del(key)
+}
+
+func (m *mockLstm) Begin() (*zendb.Txn, error) {
+	return nil, errors.New("Transactions are not supported by the mock")
+}
+
+func (m *mockLstm) Del(key []byte) ([]byte, error) {
+	if val, ok := m.data[string(key)]; ok {
+		delete(m.data, string(key))
+		return []byte(val), nil
+	}
+	return nil, zendb.ErrKeyNotFound
+}
+
+func (m *mockLstm) Write(b *zendb.WriteBatch) error {
+	return errors.New("Batches are not supported by the mock")
+}
+
+func (m *mockLstm) NewIterator(opts *zendb.IteratorOptions) (*zendb.Iterator, error) {
+	return nil, errors.New("Iterators are not supported by the mock")
 }
 
 func TestHandleSet(t *testing.T) {
@@ -61,6 +118,42 @@ func TestHandleSet(t *testing.T) {
 	}
 }
 
+func TestHandleSetWithTTL(t *testing.T) {
+	mock := &mockLstm{data: make(map[string]string)}
+	server := &Server{lstm: mock}
+	tests := []struct {
+		body   string
+		status int
+		ttl    time.Duration
+	}{
+		{`{"testKey": "testValue", "ttl": 1.5}`, StatusOK, 1500 * time.Millisecond},
+		{`{"ttl": "testValue"}`, StatusOK, 0},
+		{`{"testKey": "testValue", "ttl": 0}`, StatusBadRequest, 0},
+		{`{"testKey": "testValue", "ttl": true}`, StatusBadRequest, 0},
+		{`{"ttl": 10}`, StatusBadRequest, 0},
+	}
+	for _, test := range tests {
+		mock.ttl = 0
+		req, _ := http.NewRequest("POST", SetPath, strings.NewReader(test.body))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.handleSet).ServeHTTP(rr, req)
+		if rr.Code != test.status || mock.ttl != test.ttl {
+			t.Errorf("%s: got %v with ttl %v, want %v with ttl %v", test.body, rr.Code, mock.ttl, test.status, test.ttl)
+		}
+	}
+	if v := mock.data["ttl"]; string(v) != "testValue" {
+		t.Errorf("Expected a string ttl to be set as a pair, got %s", v)
+	}
+
+	req, _ := http.NewRequest("POST", SetPath, strings.NewReader(`{"testKey": "testValue", "ttl": 10}`))
+	req.Header.Set("If-None-Match", "*")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.handleSet).ServeHTTP(rr, req)
+	if rr.Code != StatusBadRequest {
+		t.Errorf("Expected a ttl with a precondition to be rejected, got %v", rr.Code)
+	}
+}
+
 func TestHelperGetDel(t *testing.T) {
 	mock := &mockLstm{data: map[string]string{"testKey": "testValue"}}
 	server := &Server{lstm: mock}
@@ -135,3 +228,403 @@ func TestHandleDel(t *testing.T) {
 		t.Errorf("Lstm Del method not called correctly")
 	}
 }
+
+// scanPage requests a page of a scan and decodes it.
+func scanPage(t *testing.T, server *Server, query string) scanResponse {
+	req, err := http.NewRequest("GET", ScanPath+"?"+query, nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.handleScan).ServeHTTP(rr, req)
+	if rr.Code != StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v (%s)", rr.Code, StatusOK, rr.Body.String())
+	}
+	var page scanResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	return page
+}
+
+func TestHandleScan(t *testing.T) {
+	db, err := zendb.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	for i := 0; i < 25; i++ {
+		db.Set([]byte(fmt.Sprintf("user%02d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+	db.Set([]byte("other"), []byte("value"))
+	server := NewServer(db)
+
+	page := scanPage(t, &server, "prefix=user&limit=10")
+	if len(page.Pairs) != 10 || page.Pairs[0].Key != "user00" || page.Cursor == "" {
+		t.Fatalf("Unexpected first page: %+v", page)
+	}
+	// Writes between pages are not seen by the scan.
+	db.Del([]byte("user15"))
+	db.Set([]byte("user10x"), []byte("value"))
+
+	var keys []string
+	for page.Cursor != "" {
+		page = scanPage(t, &server, "limit=10&cursor="+page.Cursor)
+		for _, p := range page.Pairs {
+			keys = append(keys, p.Key)
+		}
+	}
+	if len(keys) != 15 || keys[0] != "user10" || keys[5] != "user15" || keys[14] != "user24" {
+		t.Errorf("Unexpected keys after the first page: %v", keys)
+	}
+
+	// Once its session is gone, the scan cannot go on with the same view, and has to start again.
+	page = scanPage(t, &server, "start=user05&end=user20&limit=5")
+	cursor, _ := decodeCursor(page.Cursor)
+	server.scans.take(cursor.Session).it.Close()
+	req, _ := http.NewRequest("GET", ScanPath+"?limit=5&cursor="+page.Cursor, nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.handleScan).ServeHTTP(rr, req)
+	if rr.Code != StatusGone {
+		t.Errorf("Expected an expired cursor to be gone, got %v: %s", rr.Code, rr.Body.String())
+	}
+	req, _ = http.NewRequest("GET", ScanPath+"?cursor="+scanCursor{Session: "unknown"}.encode(), nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.handleScan).ServeHTTP(rr, req)
+	if rr.Code != StatusGone {
+		t.Errorf("Expected an unknown cursor to be gone, got %v", rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", ScanPath+"?limit=0", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.handleScan).ServeHTTP(rr, req)
+	if rr.Code != StatusBadRequest {
+		t.Errorf("Expected an invalid limit to be rejected, got %v", rr.Code)
+	}
+
+	db.Close()
+	req, _ = http.NewRequest("GET", ScanPath+"?prefix=user", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.handleScan).ServeHTTP(rr, req)
+	if rr.Code != StatusUnavailable {
+		t.Errorf("Expected a scan of a closed database to be unavailable, got %v", rr.Code)
+	}
+}
+
+func TestHandleBatch(t *testing.T) {
+	db, err := zendb.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	db.Set([]byte("key2"), []byte("value2"))
+	server := NewServer(db)
+
+	batch := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", BatchPath, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.handleBatch).ServeHTTP(rr, req)
+		return rr
+	}
+
+	// An invalid operation rejects the whole batch.
+	if rr := batch(`[{"op": "set", "key": "key1", "value": "value1"}, {"op": "put", "key": "key3"}]`); rr.Code != StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, StatusBadRequest)
+	}
+	if _, err := db.Get([]byte("key1")); err == nil {
+		t.Errorf("Expected the rejected batch not to be applied")
+	}
+
+	if rr := batch(`[{"op": "set", "key": "key1", "value": "value1"}, {"op": "del", "key": "key2"}]`); rr.Code != StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v (%s)", rr.Code, StatusOK, rr.Body.String())
+	}
+	if v, err := db.Get([]byte("key1")); err != nil || string(v) != "value1" {
+		t.Errorf("Expected value1 for key1, got %s (%v)", v, err)
+	}
+	if _, err := db.Get([]byte("key2")); err == nil {
+		t.Errorf("Expected key2 to be deleted")
+	}
+}
+
+func TestHandleTxn(t *testing.T) {
+	db, err := zendb.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	db.Set([]byte("balance"), []byte("10"))
+	server := NewServer(db)
+
+	req, _ := http.NewRequest("GET", GetPath+"?key=balance", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.handleGet).ServeHTTP(rr, req)
+	version := strings.Trim(rr.Header().Get("ETag"), `"`)
+	if version != "1" {
+		t.Fatalf("Expected the ETag to carry version 1, got %q", rr.Header().Get("ETag"))
+	}
+
+	txn := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", TxnPath, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.handleTxn).ServeHTTP(rr, req)
+		return rr
+	}
+	body := `{"reads": [{"key": "balance", "version": ` + version + `}], "writes": [{"op": "set", "key": "balance", "value": "5"}]}`
+	if rr := txn(body); rr.Code != StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v (%s)", rr.Code, StatusOK, rr.Body.String())
+	}
+	// The same read set is now stale.
+	if rr := txn(body); rr.Code != StatusConflict {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, StatusConflict)
+	}
+	if v, _ := db.Get([]byte("balance")); string(v) != "5" {
+		t.Errorf("Expected 5, got %s", v)
+	}
+
+	// A key read as absent, then set and deleted again, was written since.
+	db.Set([]byte("gone"), []byte("value"))
+	db.Del([]byte("gone"))
+	body = `{"reads": [{"key": "gone", "version": 0}], "writes": [{"op": "set", "key": "gone", "value": "1"}]}`
+	if rr := txn(body); rr.Code != StatusConflict {
+		t.Errorf("Expected a conflict on a key set and deleted since, got %v", rr.Code)
+	}
+	req, _ = http.NewRequest("GET", GetPath+"?key=gone", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.handleGet).ServeHTTP(rr, req)
+	deleted := strings.Trim(rr.Header().Get("ETag"), `"`)
+	body = `{"reads": [{"key": "gone", "version": ` + deleted + `}], "writes": [{"op": "set", "key": "gone", "value": "1"}]}`
+	if rr := txn(body); rr.Code != StatusOK {
+		t.Errorf("Expected the ETag of the deletion to commit, got %v (%s)", rr.Code, rr.Body.String())
+	}
+
+	// A closed database is not the client's fault.
+	db.Close()
+	if rr := txn(body); rr.Code != StatusUnavailable {
+		t.Errorf("Expected 503 once the database is closed, got %v", rr.Code)
+	}
+	req, _ = http.NewRequest("POST", SetPath, strings.NewReader(`{"balance": "0"}`))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.handleSet).ServeHTTP(rr, req)
+	if rr.Code != StatusUnavailable {
+		t.Errorf("Expected 503 setting once the database is closed, got %v", rr.Code)
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	db, err := zendb.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	server := NewServer(db)
+
+	set := func(body string, header, tag string) int {
+		req, _ := http.NewRequest("POST", SetPath, strings.NewReader(body))
+		req.Header.Set(header, tag)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.handleSet).ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := set(`{"key": "v1"}`, "If-None-Match", "*"); code != StatusOK {
+		t.Errorf("Expected the absent key to be set, got %v", code)
+	}
+	if code := set(`{"key": "v2"}`, "If-None-Match", "*"); code != StatusPreconditionFailed {
+		t.Errorf("Expected 412 on an existing key, got %v", code)
+	}
+
+	req, _ := http.NewRequest("GET", GetPath+"?key=key", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.handleGet).ServeHTTP(rr, req)
+	tag := rr.Header().Get("ETag")
+	if code := set(`{"key": "v2"}`, "If-Match", tag); code != StatusOK {
+		t.Errorf("Expected the key to be set on a matching ETag, got %v", code)
+	}
+	if code := set(`{"key": "v3"}`, "If-Match", tag); code != StatusPreconditionFailed {
+		t.Errorf("Expected 412 on a stale ETag, got %v", code)
+	}
+	if code := set(`{"key": "v3"}`, "If-Match", "12"); code != StatusBadRequest {
+		t.Errorf("Expected 400 on a malformed ETag, got %v", code)
+	}
+
+	req, _ = http.NewRequest("DELETE", DelPath+"?key=key", nil)
+	req.Header.Set("If-Match", tag)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.handleDel).ServeHTTP(rr, req)
+	if rr.Code != StatusPreconditionFailed {
+		t.Errorf("Expected 412 deleting with a stale ETag, got %v", rr.Code)
+	}
+	if v, _ := db.Get([]byte("key")); string(v) != "v2" {
+		t.Errorf("Expected v2, got %s", v)
+	}
+
+	// The binary PUT takes the same preconditions.
+	put := func(value string, header, tag string) int {
+		req, _ := http.NewRequest("PUT", KVPath+"?key=key", strings.NewReader(value))
+		req.Header.Set("Content-Type", OctetStream)
+		req.Header.Set(header, tag)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.handleKV).ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := put("v3", "If-None-Match", "*"); code != StatusPreconditionFailed {
+		t.Errorf("Expected 412 putting an existing key, got %v", code)
+	}
+	if code := put("v3", "If-Match", tag); code != StatusPreconditionFailed {
+		t.Errorf("Expected 412 putting with a stale ETag, got %v", code)
+	}
+	if v, _ := db.Get([]byte("key")); string(v) != "v2" {
+		t.Errorf("Expected v2 after the failed puts, got %s", v)
+	}
+	_, version, _ := db.GetWithVersion([]byte("key"))
+	if code := put("v3", "If-Match", etag(version)); code != StatusOK {
+		t.Errorf("Expected the key to be put on a matching ETag, got %v", code)
+	}
+	if v, _ := db.Get([]byte("key")); string(v) != "v3" {
+		t.Errorf("Expected v3, got %s", v)
+	}
+}
+
+func TestBinaryForms(t *testing.T) {
+	db, err := zendb.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	server := NewServer(db)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	key, value := []byte{0x00, 0xff, 'k'}, []byte{0x89, 'P', 'N', 'G', 0x00}
+	query := KVPath + "?key=" + url.QueryEscape(string(key))
+	req, _ := http.NewRequest("PUT", query, bytes.NewReader(value))
+	req.Header.Set("Content-Type", OctetStream)
+	if rr := serve(req); rr.Code != StatusOK {
+		t.Fatalf("Expected the binary value to be set, got %v: %s", rr.Code, rr.Body.String())
+	}
+	req, _ = http.NewRequest("GET", query, nil)
+	rr := serve(req)
+	if rr.Code != StatusOK || !bytes.Equal(rr.Body.Bytes(), value) || rr.Header().Get("Content-Type") != OctetStream || rr.Header().Get("ETag") == "" {
+		t.Errorf("Unexpected binary read: %v %q %v", rr.Code, rr.Body.Bytes(), rr.Header())
+	}
+	req, _ = http.NewRequest("GET", KVPath+"?key=missing", nil)
+	if rr := serve(req); rr.Code != StatusNotFound {
+		t.Errorf("Expected 404 for a missing key, got %v", rr.Code)
+	}
+	db.Set([]byte("deleted"), []byte("value"))
+	db.Del([]byte("deleted"))
+	req, _ = http.NewRequest("GET", KVPath+"?key=deleted", nil)
+	if rr := serve(req); rr.Code != StatusNotFound {
+		t.Errorf("Expected 404 for a deleted key, got %v", rr.Code)
+	}
+	req, _ = http.NewRequest("PUT", query, strings.NewReader("text"))
+	req.Header.Set("Content-Type", "text/plain")
+	if rr := serve(req); rr.Code != StatusUnsupportedMedia {
+		t.Errorf("Expected 415 for a text body, got %v", rr.Code)
+	}
+
+	b64 := base64.StdEncoding.EncodeToString
+	req, _ = http.NewRequest("GET", GetPath+"?encoding=base64&key="+url.QueryEscape(b64(key)), nil)
+	if rr := serve(req); rr.Code != StatusOK || rr.Body.String() != b64(key)+" : "+b64(value) {
+		t.Errorf("Unexpected base64 read: %v %s", rr.Code, rr.Body.String())
+	}
+	body := fmt.Sprintf(`{%q: %q}`, b64([]byte("k\x00set")), b64([]byte{0xfe}))
+	req, _ = http.NewRequest("POST", SetPath+"?encoding=base64", strings.NewReader(body))
+	if rr := serve(req); rr.Code != StatusOK {
+		t.Errorf("Expected the base64 pair to be set, got %v: %s", rr.Code, rr.Body.String())
+	}
+	if v, err := db.Get([]byte("k\x00set")); err != nil || !bytes.Equal(v, []byte{0xfe}) {
+		t.Errorf("Unexpected base64 set: %q (%v)", v, err)
+	}
+	req, _ = http.NewRequest("POST", SetPath+"?encoding=base64", strings.NewReader(`{"a2V5": "not base64!"}`))
+	if rr := serve(req); rr.Code != StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid base64 value, got %v", rr.Code)
+	}
+	req, _ = http.NewRequest("POST", SetPath+"?encoding=hex", strings.NewReader(`{"key": "value"}`))
+	if rr := serve(req); rr.Code != StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown encoding, got %v", rr.Code)
+	}
+
+	// Empty values and UTF-8 text are stored as they are.
+	req, _ = http.NewRequest("POST", SetPath, strings.NewReader(`{"clé": ""}`))
+	if rr := serve(req); rr.Code != StatusOK {
+		t.Errorf("Expected an empty value to be set, got %v: %s", rr.Code, rr.Body.String())
+	}
+	if v, err := db.Get([]byte("clé")); err != nil || len(v) != 0 {
+		t.Errorf("Expected an empty value, got %q (%v)", v, err)
+	}
+
+	req, _ = http.NewRequest("GET", ScanPath+"?encoding=base64&prefix="+url.QueryEscape(b64([]byte{0x00})), nil)
+	rr = serve(req)
+	var page scanResponse
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if rr.Code != StatusOK || len(page.Pairs) != 1 || page.Pairs[0].Key != b64(key) || page.Pairs[0].Value != b64(value) {
+		t.Errorf("Unexpected base64 scan: %v %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	db, err := zendb.Open(t.TempDir(), &zendb.Options{MaxKeySize: 8, MaxValueSize: 16})
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	server := NewServer(db)
+
+	req, _ := http.NewRequest("PUT", KVPath+"?key=key", strings.NewReader(strings.Repeat("v", 17)))
+	rr := httptest.NewRecorder()
+	server.mux.ServeHTTP(rr, req)
+	if rr.Code != StatusRequestTooLarge {
+		t.Errorf("Expected 413 for a value over the maximum size, got %v", rr.Code)
+	}
+	req, _ = http.NewRequest("POST", BatchPath, strings.NewReader(`[{"op": "set", "key": "a long key", "value": "v"}]`))
+	rr = httptest.NewRecorder()
+	server.mux.ServeHTTP(rr, req)
+	if rr.Code != StatusRequestTooLarge {
+		t.Errorf("Expected 413 for a key over the maximum size, got %v", rr.Code)
+	}
+
+	// The bodies over the bound of the endpoint are rejected while they are read.
+	large := strings.Repeat("v", int(server.maxBody(BatchPath)))
+	requests := []*http.Request{
+		httptest.NewRequest("POST", SetPath, strings.NewReader(`{"key": "`+large+`"}`)),
+		httptest.NewRequest("POST", BatchPath, strings.NewReader(`[{"op": "set", "key": "key", "value": "`+large+`"}]`)),
+		httptest.NewRequest("POST", TxnPath, strings.NewReader(`{"writes": [{"op": "set", "key": "key", "value": "`+large+`"}]}`)),
+		httptest.NewRequest("PUT", KVPath+"?key=key", strings.NewReader(large)),
+	}
+	for _, req := range requests {
+		rr := httptest.NewRecorder()
+		server.mux.ServeHTTP(rr, req)
+		if rr.Code != StatusRequestTooLarge {
+			t.Errorf("%s: expected 413 for an oversized body, got %v", req.URL, rr.Code)
+		}
+	}
+}
+
+func TestWriteStall(t *testing.T) {
+	mock := &mockLstm{data: make(map[string]string), writeErr: zendb.ErrWriteStall}
+	server := &Server{lstm: mock}
+	requests := []*http.Request{
+		httptest.NewRequest("POST", SetPath, strings.NewReader(`{"key": "value"}`)),
+		httptest.NewRequest("PUT", KVPath+"?key=key", strings.NewReader("value")),
+	}
+	handlers := []http.HandlerFunc{server.handleSet, server.handleKV}
+	for i, req := range requests {
+		rr := httptest.NewRecorder()
+		handlers[i].ServeHTTP(rr, req)
+		if rr.Code != StatusUnavailable || rr.Header().Get("Retry-After") != RetryAfter {
+			t.Errorf("%s: expected 503 with Retry-After, got %v with %q", req.URL, rr.Code, rr.Header().Get("Retry-After"))
+		}
+	}
+	if len(mock.data) != 0 {
+		t.Errorf("Expected the stalled writes to apply nothing, got %v", mock.data)
+	}
+}
@@ -2,9 +2,25 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"time"
+
+	"ZenDB/zendb"
 )
 
+// WriteStallTimeout bounds the time a request waits for the database to catch up with the writes,
+// before it is answered with 503.
+const WriteStallTimeout = 5 * time.Second
+
 func main() {
 	fmt.Println("Running Server")
-	NewServer()
+	db, err := zendb.Open(".", &zendb.Options{
+		WriteStallTimeout: WriteStallTimeout,
+		OnBackgroundError: func(err error) { log.Println(err) },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	log.Println(NewServer(db).ListenAndServe())
 }
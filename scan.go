@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ZenDB/zendb"
+)
+
+// Constants defining the range scans.
+const (
+	ScanPath         = "/scan"
+	DefaultScanLimit = 100
+	MaxScanLimit     = 1000
+	scanTimeout      = time.Minute // Idle time after which a scan session is dropped
+	maxScanSessions  = 64          // Open scan sessions, each one holds an iterator
+)
+
+// Scan errors
+var (
+	ErrInvalidLimit  = errors.New("Invalid limit, it should be between 1 and " + strconv.Itoa(MaxScanLimit))
+	ErrInvalidCursor = errors.New("Invalid cursor")
+	ErrCursorExpired = errors.New("Scan cursor expired or unknown, the scan should be started again")
+)
+
+// scanCursor is the continuation of a scan, handed to the client as an opaque string.
+// The session names the iterator kept by the server. Once the session is gone, the scan cannot go on: its pages
+// would no longer read the same view of the database.
+type scanCursor struct {
+	Session string `json:"s"`
+}
+
+// encode encodes the cursor as URL safe base64.
+func (c scanCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a cursor encoded by encode.
+func decodeCursor(s string) (scanCursor, error) {
+	var c scanCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// scanSession is an iterator kept between the pages of a scan, so that every page reads the same view of the database.
+type scanSession struct {
+	it       *zendb.Iterator
+	lastUsed time.Time
+}
+
+// scanSessions holds the open scan sessions. A session is taken out while a page is read, so that it serves one request at a time.
+type scanSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*scanSession
+}
+
+// newScanSessions creates an empty set of scan sessions.
+func newScanSessions() *scanSessions {
+	return &scanSessions{sessions: make(map[string]*scanSession)}
+}
+
+// take removes the session from the set and returns it, or nil if it expired.
+func (ss *scanSessions) take(id string) *scanSession {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.expire()
+	s := ss.sessions[id]
+	delete(ss.sessions, id)
+	return s
+}
+
+// put adds the session to the set and returns its id. The least recently used session is closed when the set is full.
+func (ss *scanSessions) put(s *scanSession) string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.expire()
+	for len(ss.sessions) >= maxScanSessions {
+		var oldest string
+		for id, other := range ss.sessions {
+			if oldest == "" || other.lastUsed.Before(ss.sessions[oldest].lastUsed) {
+				oldest = id
+			}
+		}
+		ss.sessions[oldest].it.Close()
+		delete(ss.sessions, oldest)
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	s.lastUsed = time.Now()
+	ss.sessions[id] = s
+	return id
+}
+
+// expire closes the sessions idle for longer than scanTimeout. The lock must be held.
+func (ss *scanSessions) expire() {
+	for id, s := range ss.sessions {
+		if time.Since(s.lastUsed) > scanTimeout {
+			s.it.Close()
+			delete(ss.sessions, id)
+		}
+	}
+}
+
+// scanPair is a key-value pair of a scan response.
+type scanPair struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// scanResponse is a page of a scan. Cursor is empty on the last page.
+type scanResponse struct {
+	Pairs  []scanPair `json:"pairs"`
+	Cursor string     `json:"cursor,omitempty"`
+}
+
+// prefixEnd returns the smallest key greater than every key starting with prefix, or an empty string if there is none.
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+// scanBounds returns the bounds of the scan described by the start, end and prefix queries, decoded with the codec.
+func scanBounds(c codec, start, end, prefix string) ([]byte, []byte, error) {
+	var bounds [3][]byte
+	for i, q := range []string{start, end, prefix} {
+		b, err := c.decode(q)
+		if err != nil {
+			return nil, nil, ErrInvalidKey
+		}
+		bounds[i] = b
+	}
+	lower, upper := prefixBounds(string(bounds[0]), string(bounds[1]), string(bounds[2]))
+	return []byte(lower), []byte(upper), nil
+}
+
+// prefixBounds narrows the bounds of a scan from start to end to the keys starting with prefix.
+func prefixBounds(start, end, prefix string) (string, string) {
+	lower, upper := start, end
+	if prefix != "" {
+		if prefix > lower {
+			lower = prefix
+		}
+		if pe := prefixEnd(prefix); pe != "" && (upper == "" || pe < upper) {
+			upper = pe
+		}
+	}
+	return lower, upper
+}
+
+// handleScan handles the "/scan" endpoint, returning a page of the keys of a range in order, with their values.
+// A cursor whose session expired or was dropped is answered with 410, and the scan has to start again.
+func (s *Server) handleScan(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeResponse(&response, StatusMethodNotAllowed, "Method not allowed. Only GET requests are allowed.")
+		return
+	}
+	queries := request.URL.Query()
+	c, err := requestCodec(request)
+	if err != nil {
+		writeResponse(&response, StatusBadRequest, err.Error())
+		return
+	}
+	limit := DefaultScanLimit
+	if l := queries.Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > MaxScanLimit {
+			writeResponse(&response, StatusBadRequest, ErrInvalidLimit.Error())
+			return
+		}
+	}
+
+	var session *scanSession
+	if q := queries.Get("cursor"); q != "" {
+		cursor, err := decodeCursor(q)
+		if err != nil {
+			writeResponse(&response, StatusBadRequest, err.Error())
+			return
+		}
+		if session = s.scans.take(cursor.Session); session == nil {
+			writeResponse(&response, StatusGone, ErrCursorExpired.Error())
+			return
+		}
+	} else {
+		lower, upper, err := scanBounds(c, queries.Get("start"), queries.Get("end"), queries.Get("prefix"))
+		if err != nil {
+			writeResponse(&response, StatusBadRequest, err.Error())
+			return
+		}
+		it, err := s.lstm.NewIterator(&zendb.IteratorOptions{LowerBound: lower, UpperBound: upper})
+		if err != nil {
+			writeResponse(&response, serverErrorStatus(err), err.Error())
+			return
+		}
+		it.First()
+		session = &scanSession{it: it}
+	}
+
+	page := scanResponse{Pairs: []scanPair{}}
+	it := session.it
+	for ; it.Valid() && len(page.Pairs) < limit; it.Next() {
+		page.Pairs = append(page.Pairs, scanPair{Key: c.encode(it.Key()), Value: c.encode(it.Value())})
+	}
+	if err := it.Err(); err != nil {
+		it.Close()
+		writeResponse(&response, serverErrorStatus(err), err.Error())
+		return
+	}
+	if it.Valid() {
+		page.Cursor = scanCursor{Session: s.scans.put(session)}.encode()
+	} else {
+		it.Close()
+	}
+
+	body, err := json.Marshal(page)
+	if err != nil {
+		writeResponse(&response, StatusInternalError, err.Error())
+		return
+	}
+	response.Header().Set("Content-Type", "application/json")
+	writeResponse(&response, StatusOK, string(body))
+}
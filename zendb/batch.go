@@ -0,0 +1,39 @@
+package zendb
+
+// WriteBatch collects sets and deletions, applied all together by Lstm.Write.
+type WriteBatch struct {
+	ops []Pair
+}
+
+// NewWriteBatch creates an empty batch.
+func NewWriteBatch() *WriteBatch {
+	return &WriteBatch{}
+}
+
+// Set adds the setting of a key to the batch.
+func (b *WriteBatch) Set(key, value []byte) {
+	b.ops = append(b.ops, Pair{marker: true, key: string(key), value: string(value)})
+}
+
+// Del adds the deletion of a key to the batch.
+func (b *WriteBatch) Del(key []byte) {
+	b.ops = append(b.ops, Pair{marker: false, key: string(key)})
+}
+
+// Len returns the number of operations in the batch.
+func (b *WriteBatch) Len() int {
+	return len(b.ops)
+}
+
+// Reset empties the batch.
+func (b *WriteBatch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// Write applies the batch atomically: it is recorded as a single WAL record, then applied to the memtable.
+// Unlike Del, the deletions of a batch do not check that the key exists.
+func (lstm *Lstm) Write(b *WriteBatch) error {
+	return lstm.update(func() ([]Pair, error) {
+		return append([]Pair(nil), b.ops...), nil
+	})
+}
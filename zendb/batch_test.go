@@ -0,0 +1,76 @@
+package zendb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteBatch tests that a batch is applied, and recovered from the WAL after reopening.
+func TestWriteBatch(t *testing.T) {
+	dir := t.TempDir()
+	lstm, err := Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	lstm.Set([]byte("key1"), []byte("old"))
+	b := NewWriteBatch()
+	b.Set([]byte("key1"), []byte("value1"))
+	b.Set([]byte("key2"), []byte("value2"))
+	b.Del([]byte("key2"))
+	b.Set([]byte("key3"), []byte("value3"))
+	if err := lstm.Write(b); err != nil {
+		t.Fatalf("Error writing batch: %v", err)
+	}
+	lstm.Close()
+
+	if lstm, err = Open(dir, nil); err != nil {
+		t.Fatalf("Error reopening Lstm: %v", err)
+	}
+	defer lstm.Close()
+	for key, expected := range map[string]string{"key1": "value1", "key3": "value3"} {
+		if v, err := lstm.Get([]byte(key)); err != nil || string(v) != expected {
+			t.Errorf("Expected %s for %s, got %s (%v)", expected, key, v, err)
+		}
+	}
+	if _, err := lstm.Get([]byte("key2")); !errors.Is(err, ErrKeyDeleted) {
+		t.Errorf("Expected key2 to be deleted, got %v", err)
+	}
+}
+
+// TestWriteBatchTorn tests that a batch torn by a crash is dropped as a whole during recovery.
+func TestWriteBatchTorn(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), DefaultWALName)
+	file, err := os.OpenFile(walPath, FileFlags, FilePermission)
+	if err != nil {
+		t.Fatalf("Error creating WAL: %v", err)
+	}
+	wal := &Wal{file}
+	first := []Pair{{marker: true, key: "key0", value: "value0", seq: 1}}
+	wal.RecordBatch(first)
+	record := encodeBatch([]Pair{{marker: true, key: "key1", value: "value1", seq: 2}, {marker: true, key: "key2", value: "value2", seq: 3}})
+	wal.Write(record[:len(record)-3])
+	file.Close()
+
+	mem, seq, err := Recover(walPath)
+	if err != nil {
+		t.Fatalf("Error recovering: %v", err)
+	}
+	if seq != 1 {
+		t.Errorf("Expected the last sequence number to be 1, got %d", seq)
+	}
+	if v, err := mem.Get("key0"); err != nil || v != "value0" {
+		t.Errorf("Expected value0 for key0, got %s (%v)", v, err)
+	}
+	if _, err := mem.Get("key1"); err == nil {
+		t.Errorf("Expected the torn batch to be dropped")
+	}
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatalf("Error getting WAL information: %v", err)
+	}
+	if expected := int64(len(encodeBatch(first))); info.Size() != expected {
+		t.Errorf("Expected the WAL to be truncated to %d bytes, got %d", expected, info.Size())
+	}
+}
@@ -0,0 +1,161 @@
+package zendb
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// Constants defining the legacy Bloom filter, stored in the header by the SST formats without a filter block.
+const (
+	BloomLength = 29
+	HashFuncNum = 10
+)
+
+// Constants defining the Bloom filters of the filter block.
+const (
+	FilterVersion    = 1  // Packed bitset and double hashing
+	filterHeaderSize = 6  // Filter version, number of hash functions and number of bits
+	minFilterBits    = 64 // Keeps tiny filters from saturating
+	maxFilterHashes  = 30
+)
+
+// BloomFilter is a Bloom filter sized from the number of keys it holds, stored as a packed bitset.
+// The k hash functions are derived from one 64 bits hash by double hashing: h1 + i*h2.
+type BloomFilter struct {
+	numBits   uint32
+	numHashes uint8
+	bits      []byte
+}
+
+// NewBloomFilter creates a Bloom filter sized for the given number of keys and bits per key.
+func NewBloomFilter(numKeys, bitsPerKey int) *BloomFilter {
+	numBits := uint32(numKeys * bitsPerKey)
+	if numBits < minFilterBits {
+		numBits = minFilterBits
+	}
+	// k = ln(2) * bits per key minimizes the false positive rate.
+	numHashes := int(math.Round(float64(bitsPerKey) * math.Ln2))
+	if numHashes < 1 {
+		numHashes = 1
+	}
+	if numHashes > maxFilterHashes {
+		numHashes = maxFilterHashes
+	}
+	return &BloomFilter{
+		numBits:   numBits,
+		numHashes: uint8(numHashes),
+		bits:      make([]byte, (numBits+7)/8),
+	}
+}
+
+// bloomHash returns the two hashes combined by double hashing.
+func bloomHash(data []byte) (uint32, uint32) {
+	hasher := fnv.New64a()
+	hasher.Write(data)
+	h := hasher.Sum64()
+	return uint32(h), uint32(h>>32) | 1
+}
+
+// Add adds an element to the Bloom filter.
+func (bf *BloomFilter) Add(data []byte) {
+	h1, h2 := bloomHash(data)
+	for i := uint32(0); i < uint32(bf.numHashes); i++ {
+		index := (h1 + i*h2) % bf.numBits
+		bf.bits[index/8] |= 1 << (index % 8)
+	}
+}
+
+// Test checks if an element is possibly in the Bloom filter.
+func (bf *BloomFilter) Test(data []byte) bool {
+	h1, h2 := bloomHash(data)
+	for i := uint32(0); i < uint32(bf.numHashes); i++ {
+		index := (h1 + i*h2) % bf.numBits
+		if bf.bits[index/8]&(1<<(index%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode encodes the filter block: the filter version, the number of hash functions, the number of bits and the bitset.
+func (bf *BloomFilter) Encode() []byte {
+	block := []byte{FilterVersion, bf.numHashes}
+	block = binary.LittleEndian.AppendUint32(block, bf.numBits)
+	return append(block, bf.bits...)
+}
+
+// DecodeBloomFilter decodes a filter block encoded by Encode.
+func DecodeBloomFilter(block []byte) (*BloomFilter, error) {
+	if len(block) < filterHeaderSize {
+		return nil, ErrFileNotEncodedProperly
+	}
+	if block[0] != FilterVersion {
+		return nil, ErrFileNotRecognized
+	}
+	bf := &BloomFilter{
+		numHashes: block[1],
+		numBits:   binary.LittleEndian.Uint32(block[2:]),
+		bits:      block[filterHeaderSize:],
+	}
+	if bf.numBits == 0 || uint32(len(bf.bits)) != (bf.numBits+7)/8 {
+		return nil, ErrFileNotEncodedProperly
+	}
+	return bf, nil
+}
+
+// LegacyBloomFilter is the fixed size filter of the SST formats without a filter block, stored one byte per bit.
+type LegacyBloomFilter struct {
+	size      uint
+	hashFuncs []func(data []byte) uint
+	bitset    []bool
+}
+
+// helperByteToBoolSlice converts a byte slice to a boolean slice.
+func helperByteToBoolSlice(data []byte) []bool {
+	boolSlice := make([]bool, len(data))
+
+	for i, b := range data {
+		if b == 0x01 {
+			boolSlice[i] = true
+		}
+	}
+
+	return boolSlice
+}
+
+// CreateBloomFilter creates a legacy Bloom filter from a bitset.
+func CreateBloomFilter(bitset []byte) *LegacyBloomFilter {
+	hashFuncs := make([]func(data []byte) uint, HashFuncNum)
+	for i := uint(0); i < HashFuncNum; i++ {
+		hashFuncs[i] = createHashFunc(i)
+	}
+
+	return &LegacyBloomFilter{
+		size:      uint(len(bitset)),
+		hashFuncs: hashFuncs,
+		bitset:    helperByteToBoolSlice(bitset),
+	}
+}
+
+// Test checks if an element is possibly in the legacy Bloom filter.
+func (bf *LegacyBloomFilter) Test(data []byte) bool {
+	for _, hashFunc := range bf.hashFuncs {
+		index := hashFunc(data) % bf.size
+		if !bf.bitset[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// createHashFunc generates a hash function based on a given seed.
+func createHashFunc(seed uint) func(data []byte) uint {
+	return func(data []byte) uint {
+		hasher := fnv.New32a()
+		hasher.Write(data)
+		hashValue := hasher.Sum32()
+		return (uint(hashValue) + 19*seed) % math.MaxUint32
+	}
+}
@@ -0,0 +1,49 @@
+package zendb
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestBloomFilterFalsePositives tests that a filter sized from its keys keeps a low false positive rate.
+func TestBloomFilterFalsePositives(t *testing.T) {
+	bloom := NewBloomFilter(10000, 10)
+	for i := 0; i < 10000; i++ {
+		bloom.Add([]byte(fmt.Sprintf("key%d", i)))
+	}
+	for i := 0; i < 10000; i++ {
+		if !bloom.Test([]byte(fmt.Sprintf("key%d", i))) {
+			t.Fatalf("False negative for key%d", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if bloom.Test([]byte(fmt.Sprintf("missing%d", i))) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.02 {
+		t.Errorf("False positive rate too high: %.4f", rate)
+	}
+}
+
+// TestBloomFilterEncoding tests that the filter block keeps the filter and its parameters.
+func TestBloomFilterEncoding(t *testing.T) {
+	bloom := NewBloomFilter(100, 8)
+	bloom.Add([]byte("key"))
+	decoded, err := DecodeBloomFilter(bloom.Encode())
+	if err != nil {
+		t.Fatalf("Error decoding filter block: %v", err)
+	}
+	if decoded.numBits != 800 || decoded.numHashes != 6 {
+		t.Errorf("Unexpected parameters: %d bits, %d hash functions", decoded.numBits, decoded.numHashes)
+	}
+	if !decoded.Test([]byte("key")) {
+		t.Errorf("Decoded filter lost its key")
+	}
+	block := bloom.Encode()
+	block[0] = FilterVersion + 1
+	if _, err := DecodeBloomFilter(block); err == nil {
+		t.Errorf("Expected an unknown filter version to be rejected")
+	}
+}
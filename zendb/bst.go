@@ -0,0 +1,273 @@
+package zendb
+
+import (
+	"math"
+	"unsafe"
+)
+
+// Color represents the color of a node in a Red-Black Tree.
+type Color bool
+
+const (
+	Red   Color = true
+	Black Color = false
+)
+
+// Pair represents a key-value pair in the tree.
+type Pair struct {
+	marker   bool   // Deleted or Just Set
+	key      string // Key of the pair
+	value    string // Value associated with the key
+	seq      uint64 // Sequence number of the write
+	expires  int64  // Expiry time in Unix nanoseconds, 0 when the pair never expires
+	indirect bool   // The value is a pointer into the value log
+}
+
+// expired checks if the pair has expired at the time, given in Unix nanoseconds.
+func (p Pair) expired(now int64) bool {
+	return p.expires != 0 && p.expires <= now
+}
+
+// TreeNode represents a node in a Red-Black Tree.
+type TreeNode struct {
+	elem  Pair  // Key-Value Pair
+	color Color // RB Tree Colors
+	left  *TreeNode
+	right *TreeNode
+	size  int // Size of the SubTree
+}
+
+// treeNodeSize is the memory taken by a node of the tree, besides the bytes of its key and value.
+const treeNodeSize = int(unsafe.Sizeof(TreeNode{}))
+
+// NewTree creates a new Red-Black Tree from a list of pairs.
+func NewTree() *TreeNode {
+	return nil
+}
+
+// Insert inserts a new pair into the Red-Black Tree and returns the memory it added, which is negative when
+// a replaced value was longer.
+func Insert(t **TreeNode, p Pair) int {
+	var addedLength int
+	*t, addedLength = insertRB(*t, p)
+	(*t).color = Black // Ensure the root is always black
+	return addedLength
+}
+
+// insertRB inserts a new pair into the Red-Black Tree and returns the modified tree and the added memory.
+func insertRB(root *TreeNode, p Pair) (*TreeNode, int) {
+	if root == nil {
+		return &TreeNode{
+			elem:  p,
+			color: Red, // New nodes are always red
+			left:  nil,
+			right: nil,
+			size:  1,
+		}, treeNodeSize + len(p.key) + len(p.value)
+	}
+
+	if root.elem.key == p.key && root.elem.seq == p.seq {
+		diffLength := len(p.value) - len(root.elem.value)
+		root.elem = p
+		return root, diffLength
+	}
+
+	var addedLength int
+	if less(p, root.elem) {
+		root.left, addedLength = insertRB(root.left, p)
+	} else {
+		root.right, addedLength = insertRB(root.right, p)
+	}
+
+	if isRed(root.right) && !isRed(root.left) {
+		root = rotateLeft(root)
+	}
+	if isRed(root.left) && isRed(root.left.left) {
+		root = rotateRight(root)
+	}
+	if isRed(root.left) && isRed(root.right) {
+		flipColors(root)
+	}
+
+	root.size = size(root.left) + size(root.right) + 1
+	return root, addedLength
+}
+
+// less orders the pairs by key, then the versions of a key from the newest to the oldest.
+func less(a, b Pair) bool {
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.seq > b.seq
+}
+
+// isRed checks if a node is red.
+func isRed(node *TreeNode) bool {
+	if node == nil {
+		return false
+	}
+	return node.color == Red
+}
+
+// rotateLeft performs a left rotation on the Red-Black Tree.
+func rotateLeft(h *TreeNode) *TreeNode {
+	x := h.right
+	h.right = x.left
+	x.left = h
+	x.color = h.color
+	h.color = Red
+	x.size = h.size
+	h.size = size(h.left) + size(h.right) + 1
+	return x
+}
+
+// rotateRight performs a right rotation on the Red-Black Tree.
+func rotateRight(h *TreeNode) *TreeNode {
+	x := h.left
+	h.left = x.right
+	x.right = h
+	x.color = h.color
+	h.color = Red
+	x.size = h.size
+	h.size = size(h.left) + size(h.right) + 1
+	return x
+}
+
+// flipColors flips the colors of nodes in the Red-Black Tree.
+func flipColors(h *TreeNode) {
+	h.color = Red
+	h.left.color = Black
+	h.right.color = Black
+}
+
+// size returns the size of the subtree rooted at the given node.
+func size(node *TreeNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.size
+}
+
+// Search searches for the newest version of a key in the Red-Black Tree.
+func (t *TreeNode) Search(key string) *TreeNode {
+	return t.Seek(key, math.MaxUint64)
+}
+
+// Seek searches for the newest version of a key with a sequence number lower or equal to seq.
+func (t *TreeNode) Seek(key string, seq uint64) *TreeNode {
+	var found *TreeNode
+	for n := t; n != nil; {
+		if n.elem.key > key || (n.elem.key == key && n.elem.seq <= seq) {
+			found = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if found == nil || found.elem.key != key {
+		return nil
+	}
+	return found
+}
+
+// Traverse performs an in-order traversal of the Red-Black Tree and returns a list of pairs.
+func (t *TreeNode) Traverse() []Pair {
+	if t == nil {
+		return make([]Pair, 0)
+	}
+	return append(append(t.left.Traverse(), t.elem), t.right.Traverse()...)
+}
+
+// redBlackTree is the sorted table of the default memtable. Its insertions rebalance the tree, so it cannot be read
+// while it is written.
+type redBlackTree struct {
+	root *TreeNode
+}
+
+// put inserts a version, replacing the one with the same key and sequence number, and returns the added length.
+func (t *redBlackTree) put(p Pair) int {
+	return Insert(&t.root, p)
+}
+
+// find returns the newest version of the key with a sequence number lower or equal to seq.
+func (t *redBlackTree) find(key string, seq uint64) (Pair, bool) {
+	n := t.root.Seek(key, seq)
+	if n == nil {
+		return Pair{}, false
+	}
+	return n.elem, true
+}
+
+// Traverse returns the versions in order.
+func (t *redBlackTree) Traverse() []Pair {
+	return t.root.Traverse()
+}
+
+// Len returns the number of versions.
+func (t *redBlackTree) Len() int {
+	return size(t.root)
+}
+
+// concurrent reports that the tree cannot be read while it is written.
+func (t *redBlackTree) concurrent() bool {
+	return false
+}
+
+// iterator walks the tree in place. Insertions rebalance it, so it must no longer be written.
+func (t *redBlackTree) iterator() internalIterator {
+	return &treeIterator{root: t.root}
+}
+
+// rank returns the number of versions with a key lower than key.
+func (t *TreeNode) rank(key string) int {
+	r := 0
+	for n := t; n != nil; {
+		if n.elem.key < key {
+			r += size(n.left) + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return r
+}
+
+// at returns the node of the given rank, which must be lower than the size of the tree.
+func (t *TreeNode) at(i int) *TreeNode {
+	n := t
+	for {
+		switch l := size(n.left); {
+		case i < l:
+			n = n.left
+		case i > l:
+			i -= l + 1
+			n = n.right
+		default:
+			return n
+		}
+	}
+}
+
+// treeIterator walks the versions of a tree by rank, using the sizes of the subtrees.
+type treeIterator struct {
+	root *TreeNode
+	pos  int
+}
+
+func (t *treeIterator) seek(key string) { t.pos = t.root.rank(key) }
+func (t *treeIterator) first()          { t.pos = 0 }
+func (t *treeIterator) last()           { t.pos = size(t.root) - 1 }
+func (t *treeIterator) next()           { t.pos++ }
+func (t *treeIterator) prev()           { t.pos-- }
+func (t *treeIterator) valid() bool     { return t.pos >= 0 && t.pos < size(t.root) }
+func (t *treeIterator) pair() Pair      { return t.root.at(t.pos).elem }
+func (t *treeIterator) error() error    { return nil }
+
+// Bloom Filter from a pair slice
+func GetBloom(p []Pair, bitsPerKey int) *BloomFilter {
+	bloom := NewBloomFilter(len(p), bitsPerKey)
+	for _, element := range p {
+		bloom.Add([]byte(element.key))
+	}
+	return bloom
+}
@@ -0,0 +1,67 @@
+package zendb
+
+import (
+	"errors"
+	"math"
+)
+
+// writeIf applies the write to its key if cond, called under the lock with the current version of the key,
+// returns true. It returns the current version, and ErrConditionFailed when cond returns false.
+func (lstm *Lstm) writeIf(op Pair, cond func(p Pair, exists bool) bool) (Pair, error) {
+	var p Pair
+	err := lstm.update(func() ([]Pair, error) {
+		var err error
+		p, err = lstm.searchAt(op.key, math.MaxUint64)
+		if err != nil && !errors.Is(err, ErrKeyDeleted) {
+			p = Pair{}
+			return nil, err
+		}
+		if !cond(p, err == nil) {
+			return nil, ErrConditionFailed
+		}
+		return []Pair{op}, nil
+	})
+	return p, err
+}
+
+// CompareAndSwap sets the key to value if its current value is expected, and returns ErrConditionFailed otherwise.
+func (lstm *Lstm) CompareAndSwap(key, expected, value []byte) error {
+	_, err := lstm.writeIf(Pair{marker: true, key: string(key), value: string(value)}, func(p Pair, exists bool) bool {
+		return exists && p.value == string(expected)
+	})
+	return err
+}
+
+// SetIfAbsent sets the key if it has no value, and returns ErrConditionFailed otherwise.
+func (lstm *Lstm) SetIfAbsent(key, value []byte) error {
+	_, err := lstm.writeIf(Pair{marker: true, key: string(key), value: string(value)}, func(p Pair, exists bool) bool {
+		return !exists
+	})
+	return err
+}
+
+// DelIfEquals deletes the key if its current value is expected, and returns ErrConditionFailed otherwise.
+func (lstm *Lstm) DelIfEquals(key, expected []byte) error {
+	_, err := lstm.writeIf(Pair{marker: false, key: string(key)}, func(p Pair, exists bool) bool {
+		return exists && p.value == string(expected)
+	})
+	return err
+}
+
+// SetIfVersion sets the key if it has a value of the given version, as returned by GetWithVersion,
+// and returns ErrConditionFailed otherwise.
+func (lstm *Lstm) SetIfVersion(key, value []byte, version uint64) error {
+	_, err := lstm.writeIf(Pair{marker: true, key: string(key), value: string(value)}, func(p Pair, exists bool) bool {
+		return exists && p.seq == version
+	})
+	return err
+}
+
+// DelIfVersion deletes the key if it has a value of the given version, and returns the deleted value.
+// It returns ErrConditionFailed otherwise.
+func (lstm *Lstm) DelIfVersion(key []byte, version uint64) ([]byte, error) {
+	p, err := lstm.writeIf(Pair{marker: false, key: string(key)}, func(p Pair, exists bool) bool {
+		return exists && p.seq == version
+	})
+	return valueOf(p, err), err
+}
@@ -0,0 +1,55 @@
+package zendb
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestConditionalWrites tests that the conditional writes only apply when their condition holds.
+func TestConditionalWrites(t *testing.T) {
+	lstm, err := Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+
+	if err := lstm.SetIfAbsent([]byte("key"), []byte("v1")); err != nil {
+		t.Fatalf("Error setting an absent key: %v", err)
+	}
+	if err := lstm.SetIfAbsent([]byte("key"), []byte("v2")); !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("Expected SetIfAbsent to fail on an existing key, got %v", err)
+	}
+	if err := lstm.CompareAndSwap([]byte("key"), []byte("v0"), []byte("v2")); !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("Expected CompareAndSwap to fail on another value, got %v", err)
+	}
+	if err := lstm.CompareAndSwap([]byte("key"), []byte("v1"), []byte("v2")); err != nil {
+		t.Errorf("Error swapping: %v", err)
+	}
+	if err := lstm.DelIfEquals([]byte("key"), []byte("v1")); !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("Expected DelIfEquals to fail on another value, got %v", err)
+	}
+	if err := lstm.DelIfEquals([]byte("key"), []byte("v2")); err != nil {
+		t.Errorf("Error deleting: %v", err)
+	}
+	if err := lstm.CompareAndSwap([]byte("key"), []byte(""), []byte("v3")); !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("Expected CompareAndSwap to fail on a deleted key, got %v", err)
+	}
+	if err := lstm.SetIfAbsent([]byte("key"), []byte("v3")); err != nil {
+		t.Errorf("Error setting a deleted key: %v", err)
+	}
+
+	_, version, _ := lstm.GetWithVersion([]byte("key"))
+	if err := lstm.SetIfVersion([]byte("key"), []byte("v4"), version+1); !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("Expected SetIfVersion to fail on another version, got %v", err)
+	}
+	if err := lstm.SetIfVersion([]byte("key"), []byte("v4"), version); err != nil {
+		t.Errorf("Error setting the version: %v", err)
+	}
+	if _, err := lstm.DelIfVersion([]byte("key"), version); !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("Expected DelIfVersion to fail on a stale version, got %v", err)
+	}
+	_, version, _ = lstm.GetWithVersion([]byte("key"))
+	if v, err := lstm.DelIfVersion([]byte("key"), version); err != nil || string(v) != "v4" {
+		t.Errorf("Expected v4 to be deleted, got %s (%v)", v, err)
+	}
+}
@@ -0,0 +1,228 @@
+package zendb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// TriggerCompaction wakes the compaction scheduler without waiting for it.
+func (lstm *Lstm) TriggerCompaction() {
+	select {
+	case lstm.compactC <- struct{}{}:
+	default: // A wake-up is already pending
+	}
+}
+
+// compactionLoop sleeps until a compaction is triggered, then compacts until every level is within its target,
+// and collects the garbage of the value log. A failed compaction is retried after a delay, since the writes it
+// stalls may not trigger another one.
+func (lstm *Lstm) compactionLoop(ctx context.Context) {
+	defer lstm.wg.Done()
+	var delay time.Duration
+	for {
+		if !awaitWork(ctx, lstm.compactC, delay) {
+			return
+		}
+		for ctx.Err() == nil {
+			compacted, err := lstm.compact()
+			if err != nil {
+				lstm.backgroundError(err)
+				delay = retryDelay(delay)
+				break
+			}
+			delay = 0
+			if !compacted {
+				break
+			}
+		}
+		// A write holding writeMu may be stalled until the compactions catch up, and GCValueLog may be waiting for it,
+		// so the collector waits for neither, and runs after the next compaction instead.
+		if ctx.Err() == nil && lstm.gcMu.TryLock() {
+			_, err := lstm.gcValueLog(lstm.writeMu.TryLock)
+			lstm.gcMu.Unlock()
+			if err != nil && !errors.Is(err, ErrClosed) {
+				lstm.backgroundError(err)
+			}
+		}
+	}
+}
+
+// backgroundError reports an error raised outside of a caller's request.
+func (lstm *Lstm) backgroundError(err error) {
+	if lstm.opts.OnBackgroundError != nil {
+		lstm.opts.OnBackgroundError(err)
+	}
+}
+
+// Delays before retrying a failed flush or compaction, doubling from the first to the last while it keeps failing.
+const (
+	minRetryDelay = 10 * time.Millisecond
+	maxRetryDelay = time.Second
+)
+
+// retryDelay returns the delay before retrying background work that failed after waiting for the given delay,
+// 0 when it had not failed before.
+func retryDelay(last time.Duration) time.Duration {
+	if last == 0 {
+		return minRetryDelay
+	}
+	return min(2*last, maxRetryDelay)
+}
+
+// awaitWork waits for a wake-up on c or, when delay is not 0, for the delay to pass. It returns false once ctx is done.
+func awaitWork(ctx context.Context, c <-chan struct{}, delay time.Duration) bool {
+	var retry <-chan time.Time // Never fires without a delay
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		retry = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c:
+	case <-retry:
+	}
+	return true
+}
+
+// compaction describes the files merged by one compaction.
+type compaction struct {
+	level       int         // Level of the input files
+	outputLevel int         // Level receiving the merged files
+	inputs      []*fileMeta // Files picked in the input level, level 0 ones ordered from the oldest to the newest
+	overlap     []*fileMeta // Files of the output level overlapping the inputs, when it differs from the input level
+	keepDeletes bool        // Older files of the input level may still hold the deleted keys
+}
+
+// compact runs one compaction if the compaction strategy picks one, and reports whether it did. The inputs are picked
+// under the lock, but merged and written without it, so reads and writes go on meanwhile. compactMu is held from
+// the pick to the installation of the outputs, so that two compactions never pick the same files.
+func (lstm *Lstm) compact() (bool, error) {
+	lstm.compactMu.Lock()
+	defer lstm.compactMu.Unlock()
+	lstm.mu.Lock()
+	if lstm.closed {
+		lstm.mu.Unlock()
+		return false, nil
+	}
+	c := lstm.picker.pick(lstm)
+	snapshots := lstm.snapshotSequences()
+	lstm.mu.Unlock()
+	if c == nil {
+		return false, nil
+	}
+	return true, lstm.runCompaction(c, snapshots)
+}
+
+// runCompaction merges the files of the compaction into new files of the output level, keeping the versions the
+// snapshots need. compactMu must be held, but not the lock: it is only taken to install the output files. No other
+// compaction removes files meanwhile, and the snapshots taken meanwhile only need the newest versions, which are kept.
+func (lstm *Lstm) runCompaction(c *compaction, snapshots []uint64) error {
+	// The versions of a key are ordered by their sequence numbers. Entries written before sequence numbers existed
+	// all have 0, the output level holds older data than the input level, and level 0 inputs are ordered
+	// from the oldest to the newest, so among them newer values overwrite older ones while parsing.
+	memTemp := NewMemTable()
+	for _, f := range append(append([]*fileMeta(nil), c.overlap...), c.inputs...) {
+		file, err := os.Open(lstm.sstPath(f))
+		if err != nil {
+			return err
+		}
+		err = Parse(file, memTemp)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", lstm.sstPath(f), err)
+		}
+	}
+
+	// An expired version reads as a deletion, so it is rewritten as one, and dropped like one. The value log entries
+	// the dropped versions pointed to are counted for the garbage collector.
+	kv := memTemp.table.Traverse()
+	discards := make(map[uint32]int64)
+	countPointers(discards, kv, 1)
+	now := time.Now().UnixNano()
+	for i, p := range kv {
+		if p.marker && p.expired(now) {
+			kv[i] = Pair{key: p.key, seq: p.seq}
+		}
+	}
+
+	// A deletion can be dropped once no older file may hold the key.
+	kv = collapseVersions(kv, snapshots, func(key string) bool {
+		if c.keepDeletes {
+			return false
+		}
+		lstm.mu.RLock()
+		defer lstm.mu.RUnlock()
+		return lstm.isBaseLevel(c.outputLevel, key)
+	})
+	countPointers(discards, kv, -1)
+
+	// Size-tiered compaction merges a bucket into a single level 0 file, which takes the place of the bucket.
+	var outputs []*fileMeta
+	for len(kv) > 0 {
+		n := len(kv)
+		if c.outputLevel > 0 {
+			n = splitPoint(kv, lstm.opts.TargetFileSize)
+		}
+		f, err := lstm.writeFile(c.outputLevel, kv[:n])
+		if err != nil {
+			lstm.removeFiles(outputs)
+			return err
+		}
+		outputs = append(outputs, f)
+		kv = kv[n:]
+	}
+
+	lstm.mu.Lock()
+	defer lstm.mu.Unlock()
+	e := &versionEdit{added: outputs, discards: discards}
+	e.removed = append(append(e.removed, c.inputs...), c.overlap...)
+	if err := lstm.logEdit(e); err != nil {
+		lstm.removeFiles(outputs)
+		return err
+	}
+	if c.level > 0 {
+		_, lstm.compactPointer[c.level] = keyRange(c.inputs)
+	}
+	lstm.removeFiles(c.inputs)
+	lstm.removeFiles(c.overlap)
+	lstm.wakeWriters()
+	return nil
+}
+
+// splitPoint returns the number of pairs that fill a file of the target size.
+func splitPoint(kv []Pair, target int64) int {
+	var size int64
+	for i, p := range kv {
+		size += int64(len(encodeEntry(p)))
+		// The versions of a key stay in the same file, so that the files of a level do not overlap.
+		if size >= target && (i == len(kv)-1 || kv[i+1].key != p.key) {
+			return i + 1
+		}
+	}
+	return len(kv)
+}
+
+// isBaseLevel checks if no level deeper than the given one may hold the key.
+func (lstm *Lstm) isBaseLevel(level int, key string) bool {
+	for deeper := level + 1; deeper < len(lstm.levels); deeper++ {
+		if findFile(lstm.levels[deeper], key) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// removeFiles deletes the SST files from the disk, once no iterator pins them, and drops their tables from the cache.
+func (lstm *Lstm) removeFiles(files []*fileMeta) {
+	for _, f := range files {
+		lstm.tables.drop(f.num)
+		if err := lstm.pins.remove(lstm.sstPath(f)); err != nil {
+			lstm.backgroundError(err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package zendb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestCompactionScheduler tests that flushes wake the scheduler and that compaction keeps the data readable.
+func TestCompactionScheduler(t *testing.T) {
+	errs := make(chan error, 10)
+	lstm, err := Open(t.TempDir(), &Options{
+		FlushThreshold:      20,
+		CompactionThreshold: 3,
+		OnBackgroundError:   func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+
+	for i := 0; i < 40; i++ {
+		if err := lstm.Set([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Errorf("Error setting key-value pair: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lstm.mu.RLock()
+		count := len(lstm.levels[0])
+		lstm.mu.RUnlock()
+		if count < 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Compaction did not run, %d SST files left", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-errs:
+		t.Errorf("Unexpected background error: %v", err)
+	default:
+	}
+	for i := 0; i < 40; i++ {
+		v, err := lstm.Get([]byte(fmt.Sprintf("key%d", i)))
+		if err != nil || string(v) != fmt.Sprintf("value%d", i) {
+			t.Errorf("Expected value%d, got %s (%v)", i, v, err)
+		}
+	}
+}
+
+// TestLeveledCompaction tests that the data moves down the levels while staying readable.
+func TestLeveledCompaction(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{
+		FlushThreshold:      200,
+		CompactionThreshold: 2,
+		BaseLevelSize:       1 << 10,
+		LevelSizeMultiplier: 2,
+		TargetFileSize:      256,
+	}
+	lstm, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+
+	for i := 0; i < 500; i++ {
+		if err := lstm.Set([]byte(fmt.Sprintf("key%03d", i%250)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Errorf("Error setting key-value pair: %v", err)
+		}
+	}
+	for i := 0; i < 250; i += 5 {
+		if _, err := lstm.Del([]byte(fmt.Sprintf("key%03d", i))); err != nil {
+			t.Errorf("Error deleting key: %v", err)
+		}
+	}
+	waitForFlush(t, lstm)
+	for {
+		compacted, err := lstm.compact()
+		if err != nil {
+			t.Fatalf("Error compacting: %v", err)
+		}
+		if !compacted {
+			break
+		}
+	}
+
+	lstm.mu.RLock()
+	if len(lstm.levels[2]) == 0 {
+		t.Errorf("Expected the data to reach level 2")
+	}
+	for level := 1; level < len(lstm.levels); level++ {
+		files := lstm.levels[level]
+		for i := 1; i < len(files); i++ {
+			if files[i-1].largest >= files[i].smallest {
+				t.Errorf("Level %d files %d and %d overlap", level, files[i-1].num, files[i].num)
+			}
+		}
+		if level < len(lstm.levels)-1 && levelSize(files) > lstm.levelTarget(level) {
+			t.Errorf("Level %d exceeds its target: %d bytes", level, levelSize(files))
+		}
+	}
+	lstm.mu.RUnlock()
+
+	// The levels are rebuilt from the SST files when reopening.
+	if err := lstm.Close(); err != nil {
+		t.Fatalf("Error closing Lstm: %v", err)
+	}
+	if lstm, err = Open(dir, opts); err != nil {
+		t.Fatalf("Error reopening Lstm: %v", err)
+	}
+	defer lstm.Close()
+	for i := 0; i < 250; i++ {
+		v, err := lstm.Get([]byte(fmt.Sprintf("key%03d", i)))
+		if i%5 == 0 {
+			if err == nil {
+				t.Errorf("Expected key%03d to be deleted, got %s", i, v)
+			}
+		} else if err != nil || string(v) != fmt.Sprintf("value%d", i+250) {
+			t.Errorf("Expected value%d for key%03d, got %s (%v)", i+250, i, v, err)
+		}
+	}
+}
+
+// TestCompactionStopsOnClose tests that Close stops the scheduler.
+func TestCompactionStopsOnClose(t *testing.T) {
+	lstm, err := Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	lstm.TriggerCompaction()
+	lstm.TriggerCompaction()
+
+	done := make(chan error)
+	go func() { done <- lstm.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Error closing Lstm: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the compaction scheduler")
+	}
+}
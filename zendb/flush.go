@@ -0,0 +1,95 @@
+package zendb
+
+import (
+	"context"
+	"time"
+)
+
+// immutableMemTable is a frozen memtable, no longer written to, waiting for its flush to an SST file.
+type immutableMemTable struct {
+	mem        *MemTable
+	walSegment uint64 // Last sealed segment of the WAL holding its writes
+}
+
+// triggerFlush wakes the flush goroutine without waiting for it.
+func (lstm *Lstm) triggerFlush() {
+	select {
+	case lstm.flushC <- struct{}{}:
+	default: // A wake-up is already pending
+	}
+}
+
+// flushLoop sleeps until a memtable is frozen, or the memory budget asks for the active one to be frozen, then flushes
+// the frozen memtables from the oldest to the newest.
+// A failed flush is retried on the next wake-up, or after a delay growing while it keeps failing.
+func (lstm *Lstm) flushLoop(ctx context.Context) {
+	defer lstm.wg.Done()
+	var delay time.Duration
+	for {
+		if !awaitWork(ctx, lstm.flushC, delay) {
+			return
+		}
+		lstm.freezeOnRequest()
+		for ctx.Err() == nil {
+			flushed, err := lstm.flushImmutable()
+			if err != nil {
+				lstm.backgroundError(err)
+				delay = retryDelay(delay)
+				break
+			}
+			delay = 0
+			if !flushed {
+				break
+			}
+		}
+	}
+}
+
+// flushImmutable writes the oldest frozen memtable to a level 0 SST file, and reports whether there was one.
+// The file is written without holding the lock, the memtable being immutable. The frozen memtable and its WAL
+// segments are only discarded once the file is durable and recorded in the MANIFEST.
+func (lstm *Lstm) flushImmutable() (bool, error) {
+	// The garbage collector of the value log lists the sealed segments between two flushes, since the values a flush
+	// moves are not pointed to before its file is installed.
+	lstm.flushMu.Lock()
+	defer lstm.flushMu.Unlock()
+	lstm.mu.Lock()
+	if lstm.closed || len(lstm.imm) == 0 {
+		lstm.mu.Unlock()
+		return false, nil
+	}
+	imm := lstm.imm[0]
+	snapshots := lstm.snapshotSequences()
+	f := &fileMeta{num: lstm.nextFile, level: 0}
+	lstm.nextFile++
+	lstm.mu.Unlock()
+
+	// The memtable may hold versions pointing to values moved by the garbage collector of the value log.
+	all := imm.mem.table.Traverse()
+	kv := collapseVersions(all, snapshots, nil)
+	discards := make(map[uint32]int64)
+	countPointers(discards, all, 1)
+	countPointers(discards, kv, -1)
+	kv, err := lstm.separateValues(kv)
+	if err == nil {
+		err = lstm.fillFile(f, kv)
+	}
+
+	lstm.mu.Lock()
+	defer lstm.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	if err := lstm.logEdit(&versionEdit{added: []*fileMeta{f}, discards: discards}); err != nil {
+		lstm.removeFiles([]*fileMeta{f})
+		return false, err
+	}
+	lstm.imm = lstm.imm[1:]
+	budget.release(imm.mem.size)
+	lstm.wakeWriters()
+	lstm.TriggerCompaction()
+	if err := removeWALSegments(lstm.opts.WALPath, imm.walSegment); err != nil {
+		lstm.backgroundError(err)
+	}
+	return true, nil
+}
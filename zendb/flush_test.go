@@ -0,0 +1,131 @@
+package zendb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForFlush waits until the background flush has written every frozen memtable.
+func waitForFlush(t *testing.T, lstm *Lstm) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lstm.mu.RLock()
+		pending := len(lstm.imm)
+		lstm.mu.RUnlock()
+		if pending == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Flush did not run, %d frozen memtables left", pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestImmutableMemTable tests that a full memtable is frozen with its WAL segment, stays readable until the
+// background flush writes it, and that the flush then discards the segment.
+func TestImmutableMemTable(t *testing.T) {
+	dir := t.TempDir()
+	lstm, err := Open(dir, &Options{FlushThreshold: 1000, CompactionThreshold: 100})
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+
+	// Holding the flush lock keeps the frozen memtables in memory.
+	lstm.flushMu.Lock()
+	for i := 0; i < 20; i++ {
+		if err := lstm.Set([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Errorf("Error setting key-value pair: %v", err)
+		}
+	}
+	lstm.Del([]byte("key00"))
+	lstm.mu.RLock()
+	frozen, flushed := len(lstm.imm), len(lstm.levels[0])
+	lstm.mu.RUnlock()
+	if frozen == 0 || flushed != 0 {
+		t.Errorf("Expected frozen memtables and no SST file, got %d and %d", frozen, flushed)
+	}
+	segment := walSegmentPath(lstm.opts.WALPath, 1)
+	if _, err := os.Stat(segment); err != nil {
+		t.Errorf("Expected the first WAL segment to be sealed: %v", err)
+	}
+
+	check := func() {
+		if _, err := lstm.Get([]byte("key00")); !errors.Is(err, ErrKeyDeleted) {
+			t.Errorf("Expected key00 to be deleted, got %v", err)
+		}
+		for i := 1; i < 20; i++ {
+			if v, err := lstm.Get([]byte(fmt.Sprintf("key%02d", i))); err != nil || string(v) != fmt.Sprintf("value%d", i) {
+				t.Errorf("Expected value%d, got %s (%v)", i, v, err)
+			}
+		}
+		it, err := lstm.NewIterator(nil)
+		if err != nil {
+			t.Fatalf("Error creating iterator: %v", err)
+		}
+		defer it.Close()
+		count := 0
+		for it.First(); it.Valid(); it.Next() {
+			count++
+		}
+		if count != 19 {
+			t.Errorf("Expected the iterator to visit 19 keys, got %d", count)
+		}
+	}
+	check()
+
+	lstm.flushMu.Unlock()
+	lstm.triggerFlush()
+	waitForFlush(t, lstm)
+	lstm.mu.RLock()
+	flushed = len(lstm.levels[0])
+	lstm.mu.RUnlock()
+	if flushed != frozen {
+		t.Errorf("Expected %d SST files, got %d", frozen, flushed)
+	}
+	if _, err := os.Stat(segment); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the flushed WAL segment to be deleted, got %v", err)
+	}
+	check()
+}
+
+// TestRecoverWALSegments tests that recovery replays the sealed segments of the WAL before the WAL itself.
+func TestRecoverWALSegments(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), DefaultWALName)
+	records := map[string][]Pair{
+		walSegmentPath(walPath, 1): {{marker: true, key: "key0", value: "old", seq: 1}, {marker: true, key: "key1", value: "value1", seq: 2}},
+		walSegmentPath(walPath, 2): {{marker: true, key: "key0", value: "new", seq: 3}},
+		walPath:                    {{key: "key1", seq: 4}},
+	}
+	for path, ops := range records {
+		if err := os.WriteFile(path, encodeBatch(ops), FilePermission); err != nil {
+			t.Fatalf("Error writing WAL: %v", err)
+		}
+	}
+
+	mem, seq, err := Recover(walPath)
+	if err != nil {
+		t.Fatalf("Error recovering: %v", err)
+	}
+	if seq != 4 {
+		t.Errorf("Expected the last sequence number to be 4, got %d", seq)
+	}
+	if v, err := mem.Get("key0"); err != nil || v != "new" {
+		t.Errorf("Expected the newer value for key0, got %s (%v)", v, err)
+	}
+	if _, err := mem.Get("key1"); !errors.Is(err, ErrKeyDeleted) {
+		t.Errorf("Expected key1 to be deleted, got %v", err)
+	}
+	if err := removeWALSegments(walPath, 1); err != nil {
+		t.Fatalf("Error removing WAL segments: %v", err)
+	}
+	if nums, err := walSegments(walPath); err != nil || len(nums) != 1 || nums[0] != 2 {
+		t.Errorf("Expected the second segment only, got %v (%v)", nums, err)
+	}
+}
@@ -0,0 +1,577 @@
+package zendb
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// IteratorOptions bounds the keys visited by an Iterator, and chooses the snapshot it reads.
+type IteratorOptions struct {
+	ReadOptions
+	LowerBound []byte // Smallest key visited, no lower bound when empty
+	UpperBound []byte // Keys from this one on are not visited, no upper bound when empty
+}
+
+// Iterator walks the live keys of the database in order, in either direction.
+// It reads the state of the database at the time it was created: the memtables are copied, and the SST files
+// and the value log segments are pinned until Close, so later writes and compactions do not affect it.
+// The level 0 files are held open, but the files of the deeper levels and the segments are opened as they are read.
+type Iterator struct {
+	opts        IteratorOptions
+	lower       string // Bounds of the options
+	upper       string
+	sources     []internalIterator // Ordered from the newest to the oldest
+	files       []*os.File         // Level 0 files
+	levels      []*levelIterator   // Sources of the deeper levels
+	pins        *filePins
+	pinned      []string          // Paths of the files pinned by the iterator
+	segments    map[uint32]string // Paths of the value log segments, by number
+	vlogFile    *os.File          // Value log segment read last, nil until a value is read from the value log
+	vlogSegment uint32
+	seq         uint64 // Sequence number of the last write seen
+	now         int64  // Time the expiry of the pairs is checked against, in Unix nanoseconds
+	cur         Pair
+	valid       bool
+	forward     bool // Direction of the last move, the sources are positioned past the current key
+	err         error
+}
+
+// internalIterator walks the entries of a single source, every version and deletion mark included.
+type internalIterator interface {
+	seek(key string) // Positions at the first entry with a key greater or equal to key
+	first()
+	last()
+	next()
+	prev()
+	valid() bool
+	pair() Pair
+	error() error
+}
+
+// NewIterator returns an iterator over the database. The iterator is not positioned until Seek, First or Last,
+// and must be closed after use. A nil opts visits every key.
+func (lstm *Lstm) NewIterator(opts *IteratorOptions) (*Iterator, error) {
+	lstm.mu.RLock()
+	defer lstm.mu.RUnlock()
+	if lstm.closed {
+		return nil, ErrClosed
+	}
+	it := &Iterator{pins: lstm.pins}
+	if opts != nil {
+		it.opts = *opts
+	}
+	it.lower, it.upper = string(it.opts.LowerBound), string(it.opts.UpperBound)
+	it.seq, it.now = lstm.seq, time.Now().UnixNano()
+	if it.opts.Snapshot != nil {
+		it.seq = it.opts.Snapshot.seq
+	}
+	it.segments = lstm.vlog.segmentPaths()
+	for _, path := range it.segments {
+		it.pinned = append(it.pinned, path)
+	}
+	// The active memtable is copied since it is still written. The frozen ones no longer change, so they are walked
+	// in place, and every iterator shares them.
+	it.sources = append(it.sources, &sliceIterator{pairs: lstm.mem.table.Traverse()})
+	for i := len(lstm.imm) - 1; i >= 0; i-- {
+		it.sources = append(it.sources, lstm.imm[i].mem.table.iterator())
+	}
+	// Level 0 files overlap, so they are added from the newest to the oldest, before the deeper levels. The files of
+	// a deeper level do not overlap, so they are walked one after the other by a single source.
+	var l0 []string
+	for i := len(lstm.levels[0]) - 1; i >= 0; i-- {
+		if f := lstm.levels[0][i]; it.inBounds(f.smallest, f.largest) {
+			l0 = append(l0, lstm.sstPath(f))
+		}
+	}
+	it.pinned = append(it.pinned, l0...)
+	for level := 1; level < len(lstm.levels); level++ {
+		l := &levelIterator{pos: -1}
+		for _, f := range lstm.levels[level] {
+			if it.inBounds(f.smallest, f.largest) {
+				l.largest = append(l.largest, f.largest)
+				l.paths = append(l.paths, lstm.sstPath(f))
+			}
+		}
+		if len(l.paths) > 0 {
+			it.pinned = append(it.pinned, l.paths...)
+			it.levels = append(it.levels, l)
+		}
+	}
+	lstm.pins.pin(it.pinned)
+	for _, path := range l0 {
+		source, file, err := openSource(path)
+		if file != nil {
+			it.files = append(it.files, file)
+		}
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+		it.sources = append(it.sources, source)
+	}
+	for _, l := range it.levels {
+		it.sources = append(it.sources, l)
+	}
+	return it, nil
+}
+
+// inBounds checks if the key range may hold keys visited by the iterator.
+func (it *Iterator) inBounds(smallest, largest string) bool {
+	if it.lower != "" && largest < it.lower {
+		return false
+	}
+	return it.upper == "" || smallest < it.upper
+}
+
+// openSource opens an SST file and returns a source walking its entries. The file is returned even with an error,
+// unless it could not be opened, and must be closed once the source is no longer used.
+func openSource(path string) (internalIterator, *os.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	magic, _, _, version, err := decodeHeader(file)
+	if err != nil {
+		return nil, file, fmt.Errorf("%s: %w", path, err)
+	}
+	if magic != MAGIC {
+		return nil, file, fmt.Errorf("%s: %w", path, ErrFileNotRecognized)
+	}
+	if version == VersionV1 {
+		// The first version has no index, so the whole file is read.
+		mem := NewMemTable()
+		if err := Parse(file, mem); err != nil {
+			return nil, file, fmt.Errorf("%s: %w", path, err)
+		}
+		return mem.table.iterator(), file, nil
+	}
+	b := blockFile{file}
+	if err := b.verifyHeader(); err != nil {
+		return nil, file, err
+	}
+	f, err := b.readFooter()
+	if err != nil {
+		return nil, file, fmt.Errorf("%s: %w", path, err)
+	}
+	index, err := b.readIndex(f)
+	if err != nil {
+		return nil, file, fmt.Errorf("%s: %w", path, err)
+	}
+	return &blockIterator{b: b, index: index}, file, nil
+}
+
+// Seek positions the iterator at the first key greater or equal to key.
+func (it *Iterator) Seek(key []byte) {
+	it.seek(string(key))
+}
+
+// seek positions the iterator at the first key greater or equal to key, as Seek does.
+func (it *Iterator) seek(key string) {
+	if key < it.lower {
+		key = it.lower
+	}
+	for _, s := range it.sources {
+		s.seek(key)
+	}
+	it.forward = true
+	it.findNext()
+}
+
+// First positions the iterator at the first key.
+func (it *Iterator) First() {
+	it.seek(it.lower)
+}
+
+// Last positions the iterator at the last key.
+func (it *Iterator) Last() {
+	for _, s := range it.sources {
+		if it.upper == "" {
+			s.last()
+		} else {
+			seekBefore(s, it.upper)
+		}
+	}
+	it.forward = false
+	it.findPrev()
+}
+
+// Next moves the iterator to the next key.
+func (it *Iterator) Next() {
+	if !it.Valid() {
+		return
+	}
+	if !it.forward {
+		// The sources are before the current key, move them past it.
+		for _, s := range it.sources {
+			s.seek(it.cur.key)
+			for s.valid() && s.pair().key == it.cur.key {
+				s.next()
+			}
+		}
+		it.forward = true
+	}
+	it.findNext()
+}
+
+// Prev moves the iterator to the previous key.
+func (it *Iterator) Prev() {
+	if !it.Valid() {
+		return
+	}
+	if it.forward {
+		// The sources are after the current key, move them before it.
+		for _, s := range it.sources {
+			seekBefore(s, it.cur.key)
+		}
+		it.forward = false
+	}
+	it.findPrev()
+}
+
+// seekBefore positions the source at the last entry with a key smaller than key.
+func seekBefore(s internalIterator, key string) {
+	s.seek(key)
+	if s.valid() {
+		s.prev()
+	} else if s.error() == nil {
+		s.last()
+	}
+}
+
+// findNext moves to the smallest key of the sources, and every source past its versions. The key takes the value
+// of its newest version seen by the iterator, the newest source winning between versions without sequence number.
+// Deleted keys are skipped.
+func (it *Iterator) findNext() {
+	it.find(func(a, b string) bool { return a < b }, internalIterator.next)
+}
+
+// findPrev moves to the largest key of the sources, as findNext does in the other direction.
+func (it *Iterator) findPrev() {
+	it.find(func(a, b string) bool { return a > b }, internalIterator.prev)
+}
+
+// find moves to the first key of the sources in the order of before, moving the sources with move.
+func (it *Iterator) find(before func(a, b string) bool, move func(internalIterator)) {
+	for it.sourceError() == nil {
+		var key *string
+		for _, s := range it.sources {
+			if s.valid() && (key == nil || before(s.pair().key, *key)) {
+				k := s.pair().key
+				key = &k
+			}
+		}
+		if key == nil || (it.upper != "" && *key >= it.upper) || *key < it.lower {
+			break
+		}
+		var p *Pair
+		for _, s := range it.sources {
+			for s.valid() && s.pair().key == *key {
+				if v := s.pair(); v.seq <= it.seq && (p == nil || v.seq > p.seq) {
+					p = &v
+				}
+				move(s)
+			}
+		}
+		if p != nil && p.marker && !p.expired(it.now) {
+			if p.indirect && !it.resolve(p) {
+				break
+			}
+			it.cur, it.valid = *p, true
+			return
+		}
+	}
+	it.valid = false
+}
+
+// resolve reads the value a pair points to from the value log, and reports whether it succeeded.
+// The segment read is kept open, since the values written together are read one after the other.
+func (it *Iterator) resolve(p *Pair) bool {
+	vp, err := decodeValuePointer(p.value)
+	if err == nil && (it.vlogFile == nil || it.vlogSegment != vp.segment) {
+		err = it.openSegment(vp.segment)
+	}
+	if err == nil {
+		p.value, err = readValueEntry(it.vlogFile, p.key, vp)
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	p.indirect = false
+	return true
+}
+
+// openSegment opens a value log segment pinned by the iterator in place of the one read last.
+func (it *Iterator) openSegment(num uint32) error {
+	if it.vlogFile != nil {
+		it.vlogFile.Close()
+		it.vlogFile = nil
+	}
+	path, ok := it.segments[num]
+	if !ok {
+		return fmt.Errorf("value log segment %d: %w", num, os.ErrNotExist)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	it.vlogFile, it.vlogSegment = file, num
+	return nil
+}
+
+// sourceError returns the first error met by a source. It stops the iterator.
+func (it *Iterator) sourceError() error {
+	if it.err == nil {
+		for _, s := range it.sources {
+			if err := s.error(); err != nil {
+				it.err = err
+				break
+			}
+		}
+	}
+	return it.err
+}
+
+// Valid checks if the iterator is positioned at a key.
+func (it *Iterator) Valid() bool {
+	return it.valid && it.sourceError() == nil
+}
+
+// Key returns the key the iterator is positioned at.
+func (it *Iterator) Key() []byte {
+	return []byte(it.cur.key)
+}
+
+// Value returns the value of the key the iterator is positioned at.
+func (it *Iterator) Value() []byte {
+	return []byte(it.cur.value)
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *Iterator) Err() error {
+	return it.sourceError()
+}
+
+// Close releases the SST and value log files held by the iterator, and deletes the ones removed meanwhile.
+func (it *Iterator) Close() error {
+	var err error
+	for _, file := range it.files {
+		if e := file.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, l := range it.levels {
+		if e := l.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if it.vlogFile != nil {
+		if e := it.vlogFile.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if it.pinned != nil {
+		if e := it.pins.unpin(it.pinned); e != nil && err == nil {
+			err = e
+		}
+	}
+	it.files, it.levels, it.pinned, it.vlogFile, it.sources, it.valid = nil, nil, nil, nil, nil, false
+	return err
+}
+
+// sliceIterator walks sorted pairs held in memory.
+type sliceIterator struct {
+	pairs []Pair
+	pos   int
+}
+
+func (s *sliceIterator) seek(key string) {
+	s.pos = sort.Search(len(s.pairs), func(i int) bool { return s.pairs[i].key >= key })
+}
+
+func (s *sliceIterator) first()       { s.pos = 0 }
+func (s *sliceIterator) last()        { s.pos = len(s.pairs) - 1 }
+func (s *sliceIterator) next()        { s.pos++ }
+func (s *sliceIterator) prev()        { s.pos-- }
+func (s *sliceIterator) valid() bool  { return s.pos >= 0 && s.pos < len(s.pairs) }
+func (s *sliceIterator) pair() Pair   { return s.pairs[s.pos] }
+func (s *sliceIterator) error() error { return nil }
+
+// blockIterator walks the entries of a block-based SST file, reading one data block at a time.
+type blockIterator struct {
+	b     blockFile
+	index []blockHandle
+	block int    // Position of the loaded block in the index
+	pairs []Pair // Entries of the loaded block
+	pos   int
+	err   error
+}
+
+// load reads the data block at position i of the index. Out of the index, the iterator becomes invalid.
+func (s *blockIterator) load(i int) {
+	s.block, s.pairs, s.pos = i, nil, -1
+	if s.err != nil || i < 0 || i >= len(s.index) {
+		return
+	}
+	data, err := s.b.readBlock(s.index[i].offset, s.index[i].size, "data block")
+	if err == nil {
+		err = decodeBlock(data, func(p Pair) bool {
+			s.pairs = append(s.pairs, p)
+			return true
+		})
+	}
+	s.err = err
+}
+
+func (s *blockIterator) seek(key string) {
+	i := sort.Search(len(s.index), func(i int) bool { return s.index[i].lastKey >= key })
+	s.load(i)
+	s.pos = sort.Search(len(s.pairs), func(i int) bool { return s.pairs[i].key >= key })
+}
+
+func (s *blockIterator) first() {
+	s.load(0)
+	s.pos = 0
+}
+
+func (s *blockIterator) last() {
+	s.load(len(s.index) - 1)
+	s.pos = len(s.pairs) - 1
+}
+
+func (s *blockIterator) next() {
+	if s.pos++; s.pos >= len(s.pairs) {
+		s.load(s.block + 1)
+		s.pos = 0
+	}
+}
+
+func (s *blockIterator) prev() {
+	if s.pos--; s.pos < 0 {
+		s.load(s.block - 1)
+		s.pos = len(s.pairs) - 1
+	}
+}
+
+func (s *blockIterator) valid() bool {
+	return s.err == nil && s.pos >= 0 && s.pos < len(s.pairs)
+}
+
+func (s *blockIterator) pair() Pair {
+	return s.pairs[s.pos]
+}
+
+func (s *blockIterator) error() error {
+	return s.err
+}
+
+// levelIterator walks the entries of the files of a level deeper than 0, which do not overlap. It holds a single
+// file open at a time, opening the next one as the previous one is exhausted.
+type levelIterator struct {
+	largest []string // Largest key of each file, ordered like the files
+	paths   []string
+	pos     int // Position of the open file, -1 when none is
+	source  internalIterator
+	file    *os.File
+	err     error
+}
+
+// open makes the file at position i the one read. Out of the files, the iterator becomes invalid.
+func (l *levelIterator) open(i int) {
+	if l.pos == i && l.source != nil {
+		return
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.pos, l.source, l.file = i, nil, nil
+	if l.err != nil || i < 0 || i >= len(l.paths) {
+		return
+	}
+	l.source, l.file, l.err = openSource(l.paths[i])
+	if l.err != nil {
+		l.source = nil
+	}
+}
+
+// skipForward moves to the first entry of the next files while the open one is exhausted.
+func (l *levelIterator) skipForward() {
+	for l.source != nil && !l.source.valid() && l.source.error() == nil && l.pos+1 < len(l.paths) {
+		l.open(l.pos + 1)
+		if l.source != nil {
+			l.source.first()
+		}
+	}
+}
+
+// skipBackward moves to the last entry of the previous files while the open one is exhausted.
+func (l *levelIterator) skipBackward() {
+	for l.source != nil && !l.source.valid() && l.source.error() == nil && l.pos > 0 {
+		l.open(l.pos - 1)
+		if l.source != nil {
+			l.source.last()
+		}
+	}
+}
+
+func (l *levelIterator) seek(key string) {
+	l.open(sort.Search(len(l.largest), func(i int) bool { return l.largest[i] >= key }))
+	if l.source != nil {
+		l.source.seek(key)
+		l.skipForward()
+	}
+}
+
+func (l *levelIterator) first() {
+	l.open(0)
+	if l.source != nil {
+		l.source.first()
+		l.skipForward()
+	}
+}
+
+func (l *levelIterator) last() {
+	l.open(len(l.paths) - 1)
+	if l.source != nil {
+		l.source.last()
+		l.skipBackward()
+	}
+}
+
+func (l *levelIterator) next() {
+	l.source.next()
+	l.skipForward()
+}
+
+func (l *levelIterator) prev() {
+	l.source.prev()
+	l.skipBackward()
+}
+
+func (l *levelIterator) valid() bool {
+	return l.source != nil && l.source.valid()
+}
+
+func (l *levelIterator) pair() Pair {
+	return l.source.pair()
+}
+
+func (l *levelIterator) error() error {
+	if l.err == nil && l.source != nil {
+		return l.source.error()
+	}
+	return l.err
+}
+
+// close closes the open file, if any.
+func (l *levelIterator) close() error {
+	l.source = nil
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
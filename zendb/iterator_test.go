@@ -0,0 +1,219 @@
+package zendb
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestIterator tests that the iterator merges the memtable and the SST files, hiding the deleted keys.
+func TestIterator(t *testing.T) {
+	opts := &Options{FlushThreshold: 200, CompactionThreshold: 2, BaseLevelSize: 1 << 10, TargetFileSize: 256, BlockSize: 64}
+	lstm, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+
+	for i := 0; i < 300; i++ {
+		if err := lstm.Set([]byte(fmt.Sprintf("key%03d", i%100)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatalf("Error setting key-value pair: %v", err)
+		}
+	}
+	for i := 0; i < 100; i += 3 {
+		if _, err := lstm.Del([]byte(fmt.Sprintf("key%03d", i))); err != nil {
+			t.Fatalf("Error deleting key: %v", err)
+		}
+	}
+	var expected []string
+	for i := 0; i < 100; i++ {
+		if i%3 != 0 {
+			expected = append(expected, fmt.Sprintf("key%03d", i))
+		}
+	}
+
+	it, err := lstm.NewIterator(nil)
+	if err != nil {
+		t.Fatalf("Error creating iterator: %v", err)
+	}
+	defer it.Close()
+
+	// Later writes are not seen by the iterator.
+	lstm.Set([]byte("key050"), []byte("new"))
+	lstm.Set([]byte("key200"), []byte("new"))
+
+	var keys []string
+	for it.First(); it.Valid(); it.Next() {
+		var i int
+		fmt.Sscanf(string(it.Key()), "key%d", &i)
+		if string(it.Value()) != fmt.Sprintf("value%d", i+200) {
+			t.Errorf("Expected value%d for %s, got %s", i+200, it.Key(), it.Value())
+		}
+		keys = append(keys, string(it.Key()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Error iterating: %v", err)
+	}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+
+	keys = nil
+	for it.Last(); it.Valid(); it.Prev() {
+		keys = append([]string{string(it.Key())}, keys...)
+	}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf("Expected keys %v backwards, got %v", expected, keys)
+	}
+
+	// Changing direction in the middle of the keys.
+	it.Seek([]byte("key040"))
+	if it.Next(); string(it.Key()) != "key041" {
+		t.Errorf("Expected key041 after key040, got %s", it.Key())
+	}
+	if it.Prev(); string(it.Key()) != "key040" {
+		t.Errorf("Expected key040 before key041, got %s", it.Key())
+	}
+	if it.Prev(); string(it.Key()) != "key038" {
+		t.Errorf("Expected key038 before key040, got %s", it.Key())
+	}
+}
+
+// TestIteratorBounds tests that the iterator stays within its bounds.
+func TestIteratorBounds(t *testing.T) {
+	lstm, err := Open(t.TempDir(), &Options{FlushThreshold: 100})
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+	for i := 0; i < 50; i++ {
+		lstm.Set([]byte(fmt.Sprintf("key%02d", i)), []byte("value"))
+	}
+
+	it, err := lstm.NewIterator(&IteratorOptions{LowerBound: []byte("key10"), UpperBound: []byte("key20")})
+	if err != nil {
+		t.Fatalf("Error creating iterator: %v", err)
+	}
+	defer it.Close()
+	var count int
+	for it.Seek([]byte("key00")); it.Valid(); it.Next() {
+		count++
+	}
+	if it.First(); string(it.Key()) != "key10" {
+		t.Errorf("Expected key10 first, got %s", it.Key())
+	}
+	if it.Last(); string(it.Key()) != "key19" {
+		t.Errorf("Expected key19 last, got %s", it.Key())
+	}
+	if count != 10 {
+		t.Errorf("Expected 10 keys within the bounds, got %d", count)
+	}
+	if it.Seek([]byte("key30")); it.Valid() {
+		t.Errorf("Expected no key past the upper bound, got %s", it.Key())
+	}
+}
+
+// TestIteratorPinsFiles tests that the iterator opens the files of the deeper levels one at a time, and that the
+// files compacted or collected meanwhile stay readable until it is closed, then are deleted.
+func TestIteratorPinsFiles(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{FlushThreshold: 300, CompactionThreshold: 2, BaseLevelSize: 1 << 10, TargetFileSize: 256,
+		ValueThreshold: 32, ValueLogFileSize: 512}
+	lstm, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+	value := func(i, round int) []byte { return []byte(fmt.Sprintf("%064d", i*100+round)) }
+	compactAll := func() {
+		waitForFlush(t, lstm)
+		for {
+			compacted, err := lstm.compact()
+			if err != nil {
+				t.Fatalf("Error compacting: %v", err)
+			}
+			if !compacted {
+				break
+			}
+		}
+	}
+	for i := 0; i < 200; i++ {
+		lstm.Set([]byte(fmt.Sprintf("key%03d", i)), value(i, 0))
+	}
+	compactAll()
+
+	it, err := lstm.NewIterator(nil)
+	if err != nil {
+		t.Fatalf("Error creating iterator: %v", err)
+	}
+	defer it.Close()
+	if len(it.levels) == 0 {
+		t.Fatalf("Expected files in the deeper levels")
+	}
+	for _, l := range it.levels {
+		if l.file != nil {
+			t.Errorf("Expected the files of the deeper levels to be opened as they are read")
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		lstm.Set([]byte(fmt.Sprintf("key%03d", i)), value(i, 1))
+	}
+	compactAll()
+	if _, err := lstm.GCValueLog(); err != nil {
+		t.Fatalf("Error collecting the value log: %v", err)
+	}
+
+	count := 0
+	for it.First(); it.Valid(); it.Next() {
+		if want := value(count, 0); string(it.Value()) != string(want) {
+			t.Errorf("Expected %s for %s, got %s", want, it.Key(), it.Value())
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Error iterating: %v", err)
+	}
+	if count != 200 {
+		t.Errorf("Expected 200 keys, got %d", count)
+	}
+
+	removed := func() int {
+		n := 0
+		for _, path := range it.pinned {
+			if _, err := os.Stat(path); err != nil {
+				n++
+			}
+		}
+		return n
+	}
+	if n := removed(); n != 0 {
+		t.Errorf("Expected the pinned files to stay until the iterator is closed, %d were deleted", n)
+	}
+	pinned := it.pinned
+	it.Close()
+	lstm.mu.RLock()
+	live := make(map[string]bool)
+	for _, files := range lstm.levels {
+		for _, f := range files {
+			live[lstm.sstPath(f)] = true
+		}
+	}
+	for _, path := range lstm.vlog.segmentPaths() {
+		live[path] = true
+	}
+	lstm.mu.RUnlock()
+	deleted := 0
+	for _, path := range pinned {
+		_, err := os.Stat(path)
+		if live[path] != (err == nil) {
+			t.Errorf("Expected %s to exist only while live, got %v", path, err)
+		}
+		if err != nil {
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		t.Errorf("Expected the files removed while pinned to be deleted on close")
+	}
+}
@@ -0,0 +1,168 @@
+package zendb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// sstNameRegex matches the SST file names, level 0 files carry no level suffix.
+var sstNameRegex = regexp.MustCompile(`^ZenFile(\d+)(?:_L(\d+))?\.sst$`)
+
+// fileMeta describes a live SST file.
+type fileMeta struct {
+	num      int    // File number, increasing with each new file
+	level    int    // Level the file belongs to
+	size     int64  // Size of the file in bytes
+	smallest string // Smallest key stored in the file
+	largest  string // Largest key stored in the file
+}
+
+// sstName returns the name of the SST file with the given number and level.
+func sstName(num, level int) string {
+	if level == 0 {
+		return path1 + fmt.Sprint(num) + path2
+	}
+	return path1 + fmt.Sprint(num) + "_L" + fmt.Sprint(level) + path2
+}
+
+// sstPath returns the path of the SST file described by f.
+func (lstm *Lstm) sstPath(f *fileMeta) string {
+	return filepath.Join(lstm.opts.SSTDir, sstName(f.num, f.level))
+}
+
+// overlaps checks if the file may hold keys in the range [smallest, largest].
+func (f *fileMeta) overlaps(smallest, largest string) bool {
+	return f.smallest <= largest && smallest <= f.largest
+}
+
+// contains checks if the key is within the file's key range.
+func (f *fileMeta) contains(key string) bool {
+	return f.smallest <= key && key <= f.largest
+}
+
+// readFileMeta builds the description of an existing SST file by parsing it.
+func readFileMeta(path string, num, level int) (*fileMeta, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	mem := NewMemTable()
+	if err := Parse(file, mem); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	kv := mem.table.Traverse()
+	f := &fileMeta{num: num, level: level, size: info.Size()}
+	if len(kv) > 0 {
+		f.smallest, f.largest = kv[0].key, kv[len(kv)-1].key
+	}
+	return f, nil
+}
+
+// levelsFromDir rebuilds the levels from the SST files found in the directory, and returns the next free file number.
+// It is only used to migrate a database created before the MANIFEST, which is otherwise the source of truth.
+func levelsFromDir(directory string, maxLevels int) ([][]*fileMeta, int, error) {
+	levels := make([][]*fileMeta, maxLevels)
+	nextFile := 1
+
+	files, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, file := range files {
+		match := sstNameRegex.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+		num, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		level := 0
+		if match[2] != "" {
+			if level, err = strconv.Atoi(match[2]); err != nil || level >= maxLevels {
+				continue
+			}
+		}
+		f, err := readFileMeta(filepath.Join(directory, file.Name()), num, level)
+		if err != nil {
+			return nil, 0, err
+		}
+		levels[level] = append(levels[level], f)
+		if num >= nextFile {
+			nextFile = num + 1
+		}
+	}
+
+	// Level 0 is ordered from the oldest to the newest file, the other levels by key range.
+	sort.Slice(levels[0], func(i, j int) bool { return levels[0][i].num < levels[0][j].num })
+	for level := 1; level < maxLevels; level++ {
+		sortByKey(levels[level])
+	}
+	return levels, nextFile, nil
+}
+
+// sortByKey sorts files of a level other than 0 by their smallest key.
+func sortByKey(files []*fileMeta) {
+	sort.Slice(files, func(i, j int) bool { return files[i].smallest < files[j].smallest })
+}
+
+// levelSize returns the total size in bytes of the files.
+func levelSize(files []*fileMeta) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	return total
+}
+
+// levelTarget returns the size target of a level other than 0.
+func (lstm *Lstm) levelTarget(level int) int64 {
+	target := lstm.opts.BaseLevelSize
+	for i := 1; i < level; i++ {
+		target *= int64(lstm.opts.LevelSizeMultiplier)
+	}
+	return target
+}
+
+// overlapping returns the files of the level whose key range intersects [smallest, largest].
+func (lstm *Lstm) overlapping(level int, smallest, largest string) []*fileMeta {
+	var res []*fileMeta
+	for _, f := range lstm.levels[level] {
+		if f.overlaps(smallest, largest) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// findFile returns the file of a level other than 0 that may contain the key, or nil.
+func findFile(files []*fileMeta, key string) *fileMeta {
+	i := sort.Search(len(files), func(i int) bool { return files[i].largest >= key })
+	if i < len(files) && files[i].smallest <= key {
+		return files[i]
+	}
+	return nil
+}
+
+// keyRange returns the smallest and largest keys covered by the files.
+func keyRange(files []*fileMeta) (string, string) {
+	smallest, largest := files[0].smallest, files[0].largest
+	for _, f := range files[1:] {
+		if f.smallest < smallest {
+			smallest = f.smallest
+		}
+		if f.largest > largest {
+			largest = f.largest
+		}
+	}
+	return smallest, largest
+}
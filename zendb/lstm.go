@@ -0,0 +1,579 @@
+package zendb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Constants defining the sst files' names.
+const (
+	path1 = "ZenFile"
+	path2 = ".sst"
+)
+
+// Errors for various situations.
+var (
+	ErrFileNotRecognized      = errors.New("File not recognized")
+	ErrFileNotEncodedProperly = errors.New("File not encoded properly")
+	ErrCorruptFile            = errors.New("The file is corrupt")
+	ErrKeyNotFound            = errors.New("Key not found")
+	ErrKeyDeleted             = errors.New("Key does not exist")
+	ErrKeyCannotBeInFile      = errors.New("Key cannot be in current file")
+	ErrDeletion               = errors.New("Error While Deleting")
+	ErrClosed                 = errors.New("Database is closed")
+	ErrConflict               = errors.New("Transaction conflict, a key it read was written since")
+	ErrTxnDone                = errors.New("Transaction already committed or rolled back")
+	ErrConditionFailed        = errors.New("Precondition failed")
+	ErrInvalidTTL             = errors.New("Invalid TTL, it should be positive")
+	ErrKeyTooLarge            = errors.New("Key too large")
+	ErrValueTooLarge          = errors.New("Value too large")
+	ErrWriteStall             = errors.New("Write stalled, the flush and the compaction are behind")
+)
+
+// Lstm represents the main storage manager, the LSM Tree
+type Lstm struct {
+	opts            *Options
+	mem             *MemTable
+	imm             []*immutableMemTable // Frozen memtables waiting for their flush, from the oldest to the newest
+	wal             *Wal
+	walSegment      uint64 // Number given to the next sealed segment of the WAL
+	vlog            *valueLog
+	manifest        *manifest
+	levels          [][]*fileMeta          // SST files of each level, level 0 ordered from the oldest to the newest
+	tables          *tableCache            // Filters and indexes of the SST files
+	pins            *filePins              // SST files and value log segments the iterators may still open
+	discards        map[uint32]int64       // Bytes of each value log segment the LSM tree no longer points to
+	nextFile        int                    // Number given to the next SST file
+	seq             uint64                 // Sequence number of the last write
+	snapshots       map[*Snapshot]struct{} // Live snapshots
+	compactPointer  []string               // Largest key compacted last in each level
+	picker          compactionPicker       // Compaction strategy
+	mu              sync.RWMutex
+	closed          bool
+	compactC        chan struct{}      // Wakes the compaction scheduler
+	compactMu       sync.Mutex         // Held by a compaction from its pick to its installation, held before mu
+	gcMu            sync.Mutex         // Held by the garbage collector of the value log, held before writeMu
+	flushC          chan struct{}      // Wakes the flush goroutine
+	flushMu         sync.Mutex         // Held by a flush while it writes its SST file without mu
+	writeMu         sync.Mutex         // Serializes the writes, held before flushMu and mu
+	writeC          chan struct{}      // Closed and replaced to wake the stalled writes
+	freezeRequested atomic.Bool        // Set when the memory budget asks for the active memtable to be frozen
+	cancel          context.CancelFunc // Stops the background goroutines
+	wg              sync.WaitGroup     // Tracks the background goroutines
+}
+
+// DB is the handle returned by Open.
+type DB = Lstm
+
+// Set adds a new key-value pair to the storage manager. Keys and values are arbitrary bytes, and values may be empty.
+func (lstm *Lstm) Set(key, value []byte) error {
+	return lstm.update(func() ([]Pair, error) {
+		return []Pair{{marker: true, key: string(key), value: string(value)}}, nil
+	})
+}
+
+// SetWithTTL adds a new key-value pair that expires once the ttl has elapsed. An expired key reads as deleted.
+func (lstm *Lstm) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	return lstm.update(func() ([]Pair, error) {
+		return []Pair{{marker: true, key: string(key), value: string(value), expires: time.Now().Add(ttl).UnixNano()}}, nil
+	})
+}
+
+// update runs a write. Writes are serialized by writeMu: prepare is called under the lock once the write has room,
+// and returns the operations to write, or an error to write nothing. Nothing is written either if a key or a value
+// exceeds its maximum size. The lock is not held while the operations are recorded in the WAL, so reads go on.
+func (lstm *Lstm) update(prepare func() ([]Pair, error)) error {
+	lstm.writeMu.Lock()
+	defer lstm.writeMu.Unlock()
+	lstm.mu.Lock()
+	err := lstm.makeRoomForWrite()
+	var ops []Pair
+	if err == nil {
+		ops, err = prepare()
+	}
+	for i := 0; err == nil && i < len(ops); i++ {
+		err = lstm.checkSize(ops[i])
+	}
+	lstm.mu.Unlock()
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	return lstm.write(ops)
+}
+
+// write gives the next sequence numbers to the operations and applies them, then publishes the sequence number
+// of the last one, making them visible to the snapshots taken from then on. writeMu must be held, but not the lock.
+func (lstm *Lstm) write(ops []Pair) error {
+	seq := lstm.seq
+	for i := range ops {
+		seq++
+		ops[i].seq = seq
+	}
+	if err := lstm.apply(ops); err != nil {
+		return err
+	}
+	lstm.mu.Lock()
+	defer lstm.mu.Unlock()
+	lstm.seq = seq
+	lstm.memFlush()
+	return nil
+}
+
+// apply records the operations, which already have their sequence numbers, in the WAL as a single record,
+// then inserts them in the memtable. writeMu must be held, but not the lock: a skiplist memtable is read while it is
+// written, and the lock is only taken to insert into a red-black tree.
+func (lstm *Lstm) apply(ops []Pair) error {
+	if err := lstm.wal.RecordBatch(ops); err != nil {
+		return err
+	}
+	mem := lstm.mem
+	if !mem.table.concurrent() {
+		lstm.mu.Lock()
+		defer lstm.mu.Unlock()
+	}
+	size := mem.size
+	for _, p := range ops {
+		mem.put(p)
+	}
+	budget.grow(mem.size - size)
+	return nil
+}
+
+// checkSize checks that the key and the value of the pair do not exceed the maximum sizes of the options.
+func (lstm *Lstm) checkSize(p Pair) error {
+	if len(p.key) > lstm.opts.MaxKeySize {
+		return fmt.Errorf("%w: %d bytes, the maximum is %d", ErrKeyTooLarge, len(p.key), lstm.opts.MaxKeySize)
+	}
+	if len(p.value) > lstm.opts.MaxValueSize {
+		return fmt.Errorf("%w: %d bytes, the maximum is %d", ErrValueTooLarge, len(p.value), lstm.opts.MaxValueSize)
+	}
+	return nil
+}
+
+// MaxSizes returns the maximum sizes in bytes of the keys and the values accepted by the writes.
+func (lstm *Lstm) MaxSizes() (int, int) {
+	return lstm.opts.MaxKeySize, lstm.opts.MaxValueSize
+}
+
+// LastSequence returns the sequence number of the last write.
+func (lstm *Lstm) LastSequence() uint64 {
+	lstm.mu.RLock()
+	defer lstm.mu.RUnlock()
+	return lstm.seq
+}
+
+// Search retrieves the value associated with a key from the storage. The lock must be held.
+func (lstm *Lstm) Search(key []byte) ([]byte, error) {
+	p, err := lstm.searchAt(string(key), math.MaxUint64)
+	return valueOf(p, err), err
+}
+
+// valueOf returns the value of the pair found by a search, nil when the search failed.
+// The internal pairs hold keys and values as strings, which carry arbitrary bytes.
+func valueOf(p Pair, err error) []byte {
+	if err != nil {
+		return nil
+	}
+	return []byte(p.value)
+}
+
+// versionOf returns the version of the pair found by a search: the sequence number of the key's newest write,
+// its deletion or its expired write included, so that any write to the key changes it. It is 0 for a key never
+// written, or written before sequence numbers existed, and when the search failed.
+func versionOf(p Pair, err error) uint64 {
+	if err != nil && !errors.Is(err, ErrKeyDeleted) {
+		return 0
+	}
+	return p.seq
+}
+
+// searchAt retrieves the version of a key seen as of the sequence number. An expired version reads as a deletion.
+func (lstm *Lstm) searchAt(key string, seq uint64) (Pair, error) {
+	p, err := lstm.findAt(key, seq)
+	if err == nil && p.expired(time.Now().UnixNano()) {
+		return p, ErrKeyDeleted
+	}
+	if err == nil && p.indirect {
+		if p.value, err = lstm.vlog.read(p.key, p.value); err != nil {
+			log.Println(err)
+		}
+		p.indirect = false
+	}
+	return p, err
+}
+
+// findAt finds the version of a key seen as of the sequence number, from the memtable, through the frozen
+// memtables from the newest to the oldest, to the deepest level.
+func (lstm *Lstm) findAt(key string, seq uint64) (Pair, error) {
+	p, err := lstm.mem.getAt(key, seq)
+	for i := len(lstm.imm) - 1; i >= 0 && errors.Is(err, ErrKeyNotFound); i-- {
+		p, err = lstm.imm[i].mem.getAt(key, seq)
+	}
+	if err != nil && errors.Is(err, ErrKeyNotFound) {
+		// Level 0 files overlap, so all of them are searched from the newest to the oldest.
+		for i := len(lstm.levels[0]) - 1; i >= 0; i-- {
+			if f := lstm.levels[0][i]; f.contains(key) {
+				if p, err := lstm.searchFile(f, key, seq); err == nil || errors.Is(err, ErrKeyDeleted) {
+					return p, err
+				}
+			}
+		}
+		// The other levels hold at most one file that may contain the key.
+		for level := 1; level < len(lstm.levels); level++ {
+			if f := findFile(lstm.levels[level], key); f != nil {
+				if p, err := lstm.searchFile(f, key, seq); err == nil || errors.Is(err, ErrKeyDeleted) {
+					return p, err
+				}
+			}
+		}
+		return Pair{}, ErrKeyDeleted
+	}
+	return p, err
+}
+
+// searchFile searches for a key in an SST file, as of the sequence number. The search is over when it finds the key or its deletion.
+func (lstm *Lstm) searchFile(f *fileMeta, key string, seq uint64) (Pair, error) {
+	file, err := os.Open(lstm.sstPath(f))
+	if err != nil {
+		log.Println(err)
+		return Pair{}, err
+	}
+	defer file.Close()
+	// The filter and the index are read once per file, the lookup then reads the data blocks only.
+	t, err := lstm.tables.get(f.num, file)
+	var p Pair
+	switch {
+	case err != nil:
+	case t == nil:
+		p, err = searchAt(key, seq, file)
+	default:
+		p, err = t.search(key, seq, file)
+	}
+	if errors.Is(err, ErrFileNotRecognized) || errors.Is(err, ErrFileNotEncodedProperly) || errors.Is(err, ErrCorruptFile) {
+		log.Println(err)
+	}
+	return p, err
+}
+
+// Get retrieves the value associated with a key from the storage manager.
+func (lstm *Lstm) Get(key []byte) ([]byte, error) {
+	return lstm.GetWithOptions(key, nil)
+}
+
+// GetWithOptions retrieves the value associated with a key, as of the snapshot of the options if any.
+// Without one, it reads as of the last published sequence number: the operations of a batch being inserted into a
+// skiplist memtable are only seen together, once the whole batch is applied.
+func (lstm *Lstm) GetWithOptions(key []byte, ro *ReadOptions) ([]byte, error) {
+	lstm.mu.RLock()
+	defer lstm.mu.RUnlock()
+	if lstm.closed {
+		return nil, ErrClosed
+	}
+	p, err := lstm.searchAt(string(key), ro.sequence(lstm.seq))
+	return valueOf(p, err), err
+}
+
+// GetWithVersion retrieves the value associated with a key, with its version: the sequence number of the key's
+// last write, 0 if the key was never written or was written before sequence numbers existed. A deleted or expired key
+// returns ErrKeyDeleted with the version of its deletion or expired write.
+func (lstm *Lstm) GetWithVersion(key []byte) ([]byte, uint64, error) {
+	lstm.mu.RLock()
+	defer lstm.mu.RUnlock()
+	if lstm.closed {
+		return nil, 0, ErrClosed
+	}
+	p, err := lstm.searchAt(string(key), lstm.seq)
+	return valueOf(p, err), versionOf(p, err), err
+}
+
+// Del removes a key from the storage manager.
+func (lstm *Lstm) Del(key []byte) ([]byte, error) {
+	var v []byte
+	err := lstm.update(func() ([]Pair, error) {
+		var err error
+		if v, err = lstm.Search(key); err != nil {
+			return nil, err
+		}
+		return []Pair{{marker: false, key: string(key)}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// memFlush freezes the memtable once it reaches the flush threshold, or earlier when the memory budget runs short:
+// its WAL is sealed, it joins the frozen memtables, and a new memtable takes its place. The flush goroutine writes it
+// to an SST file, so writers and readers are not blocked while the file is written. On failure, reported as
+// a background error, the memtable stays active, and freezing is retried by the next write. writeMu and the lock
+// must be held.
+func (lstm *Lstm) memFlush() {
+	requested := lstm.freezeRequested.Swap(false)
+	if lstm.mem.size == 0 {
+		return
+	}
+	if lstm.mem.size < lstm.opts.FlushThreshold && !requested {
+		if !budget.exceeded() {
+			return
+		}
+		budget.requestFreeze(lstm)
+	}
+	if err := lstm.wal.seal(walSegmentPath(lstm.opts.WALPath, lstm.walSegment)); err != nil {
+		lstm.backgroundError(err)
+		return
+	}
+	lstm.imm = append(lstm.imm, &immutableMemTable{mem: lstm.mem, walSegment: lstm.walSegment})
+	lstm.walSegment++
+	budget.freeze(lstm.mem.size)
+	lstm.mem = newMemTable(lstm.opts.MemTableType)
+	lstm.triggerFlush()
+}
+
+// logEdit records the edit in the MANIFEST, then installs it in the levels and the discarded bytes. The bytes
+// discarded from the segments the garbage collector already deleted are left out.
+func (lstm *Lstm) logEdit(e *versionEdit) error {
+	e.nextFile = lstm.nextFile
+	e.lastSeq = lstm.seq
+	for num := range e.discards {
+		if !lstm.vlog.has(num) {
+			delete(e.discards, num)
+		}
+	}
+	if err := lstm.manifest.log(e); err != nil {
+		return err
+	}
+	lstm.levels = applyEdit(lstm.levels, e)
+	applyDiscards(lstm.discards, e)
+	for len(lstm.compactPointer) < len(lstm.levels) {
+		lstm.compactPointer = append(lstm.compactPointer, "")
+	}
+	return nil
+}
+
+// writeFile writes the sorted pairs to a new SST file of the given level. The lock must not be held: it is only
+// taken to number the file.
+func (lstm *Lstm) writeFile(level int, kv []Pair) (*fileMeta, error) {
+	lstm.mu.Lock()
+	f := &fileMeta{num: lstm.nextFile, level: level}
+	lstm.nextFile++
+	lstm.mu.Unlock()
+	if err := lstm.fillFile(f, kv); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// fillFile writes the sorted pairs to the SST file described by f, and completes its description.
+func (lstm *Lstm) fillFile(f *fileMeta, kv []Pair) error {
+	if err := writeSST(lstm.sstPath(f), kv, lstm.opts); err != nil {
+		return err
+	}
+	info, err := os.Stat(lstm.sstPath(f))
+	if err != nil {
+		return err
+	}
+	f.size = info.Size()
+	f.smallest, f.largest = kv[0].key, kv[len(kv)-1].key
+	return nil
+}
+
+// Recover recovers the storage manager state from the Write-Ahead Log (WAL), with the sequence number of its last write.
+// The sealed segments of the WAL, holding the writes of the memtables frozen but not flushed, are replayed first.
+// A torn or corrupt record at the end of the WAL is dropped, as TolerateCorruptTailRecovery does.
+func Recover(walPath string) (*MemTable, uint64, error) {
+	return recoverWAL(walPath, NewMemTable(), TolerateCorruptTailRecovery)
+}
+
+// recoverWAL recovers the writes of the WAL into the memtable, with the sequence number of its last write.
+func recoverWAL(walPath string, mem *MemTable, mode WALRecoveryMode) (*MemTable, uint64, error) {
+	log.Println("Recovering...")
+	defer log.Println("Recovering Complete\nReady For Requests")
+	segments, err := walSegments(walPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	var seq uint64
+	for _, num := range segments {
+		s, err := replayWAL(walSegmentPath(walPath, num), mem, mode)
+		if err != nil {
+			return nil, 0, err
+		}
+		seq = max(seq, s)
+	}
+	s, err := replayWAL(walPath, mem, mode)
+	if err != nil {
+		return nil, 0, err
+	}
+	return mem, max(seq, s), nil
+}
+
+// replayWAL applies the records of a WAL file to the memtable, and returns the sequence number of its last write.
+// A torn or corrupt record is handled as the recovery mode says; the ones dropped at the end of the file are
+// truncated, so that the next writes are appended after the valid records.
+func replayWAL(walPath string, mem *MemTable, mode WALRecoveryMode) (uint64, error) {
+	data, err := os.ReadFile(walPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var seq uint64
+	for offset := 0; offset < len(data); {
+		ops, size, err := decodeRecord(data[offset:])
+		if err != nil {
+			next := resyncRecord(data, offset)
+			if mode == AbsoluteConsistencyRecovery || (next >= 0 && mode != SkipCorruptRecordsRecovery) {
+				return 0, fmt.Errorf("%s: record at offset %d: %w", walPath, offset, err)
+			}
+			if next < 0 {
+				// A record torn by a crash was never acknowledged, so it is dropped from the log.
+				log.Printf("%s: dropping the records from offset %d: %v", walPath, offset, err)
+				if err := os.Truncate(walPath, int64(offset)); err != nil {
+					return 0, err
+				}
+				break
+			}
+			log.Printf("%s: skipping the record at offset %d: %v", walPath, offset, err)
+			offset = next
+			continue
+		}
+		for _, p := range ops {
+			mem.put(p)
+			seq = max(seq, p.seq)
+		}
+		offset += size
+	}
+	return seq, nil
+}
+
+// Open opens the LSM Tree stored in dir, creating it if needed. A nil opts uses DefaultOptions.
+func Open(dir string, opts *Options) (*DB, error) {
+	opts = opts.withDefaults(dir)
+	if err := os.MkdirAll(opts.SSTDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.WALPath), os.ModePerm); err != nil {
+		return nil, err
+	}
+	mem, seq, err := recoverWAL(opts.WALPath, newMemTable(opts.MemTableType), opts.WALRecoveryMode)
+	if err != nil {
+		return nil, err
+	}
+	// The recovered memtable holds the writes of the sealed segments, which are deleted once it is flushed.
+	segments, err := walSegments(opts.WALPath)
+	if err != nil {
+		return nil, err
+	}
+	walSegment := uint64(1)
+	if len(segments) > 0 {
+		walSegment = segments[len(segments)-1] + 1
+	}
+	levels, discards, nextFile, lastSeq, err := loadManifest(opts.SSTDir, opts.MaxLevels)
+	if errors.Is(err, os.ErrNotExist) {
+		discards = make(map[uint32]int64)
+		levels, nextFile, err = levelsFromDir(opts.SSTDir, opts.MaxLevels)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pins := newFilePins()
+	vlog, err := openValueLog(opts.ValueLogDir, opts.ValueLogFileSize, pins)
+	if err != nil {
+		return nil, err
+	}
+	// A segment deleted by the garbage collector before a crash let it log the deletion leaves its bytes behind.
+	for num := range discards {
+		if !vlog.has(num) {
+			delete(discards, num)
+		}
+	}
+	// The writes still in the WAL are newer than the ones flushed to the SST files.
+	seq = max(seq, lastSeq)
+	// Every open starts a new MANIFEST holding only the live files.
+	man, err := createManifest(opts.SSTDir, nextFile, levels, discards, nextFile+1, seq)
+	if err != nil {
+		return nil, err
+	}
+	nextFile++
+	if err := removeObsoleteFiles(opts.SSTDir, levels, man.name); err != nil {
+		man.file.Close()
+		return nil, err
+	}
+	file, err := os.OpenFile(opts.WALPath, FileFlags, FilePermission)
+	if err != nil {
+		man.file.Close()
+		return nil, err
+	}
+	resLstm := &Lstm{
+		opts:           opts,
+		mem:            mem,
+		wal:            &Wal{file},
+		walSegment:     walSegment,
+		vlog:           vlog,
+		manifest:       man,
+		levels:         levels,
+		tables:         newTableCache(),
+		pins:           pins,
+		discards:       discards,
+		nextFile:       nextFile,
+		seq:            seq,
+		snapshots:      make(map[*Snapshot]struct{}),
+		compactPointer: make([]string, len(levels)),
+		picker:         newCompactionPicker(opts.CompactionStyle),
+		compactC:       make(chan struct{}, 1),
+		flushC:         make(chan struct{}, 1),
+		writeC:         make(chan struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	resLstm.cancel = cancel
+	budget.register(resLstm, mem.size)
+	resLstm.wg.Add(2)
+	go resLstm.compactionLoop(ctx)
+	go resLstm.flushLoop(ctx)
+	resLstm.TriggerCompaction()
+	return resLstm, nil
+}
+
+// Close stops the background flush and compaction, and closes the Write-Ahead Log and the MANIFEST.
+// The frozen memtables not flushed yet are recovered from the sealed segments of the WAL on the next open.
+func (lstm *Lstm) Close() error {
+	lstm.mu.Lock()
+	if lstm.closed {
+		lstm.mu.Unlock()
+		return ErrClosed
+	}
+	lstm.closed = true
+	lstm.cancel()
+	lstm.wakeWriters()
+	lstm.mu.Unlock()
+
+	lstm.wg.Wait()
+	// A write under way finishes before the WAL is closed, the stalled ones return ErrClosed.
+	lstm.writeMu.Lock()
+	defer lstm.writeMu.Unlock()
+	total := lstm.mem.size
+	for _, imm := range lstm.imm {
+		total += imm.mem.size
+	}
+	budget.unregister(lstm, lstm.mem.size, total)
+	err := lstm.manifest.file.Close()
+	if e := lstm.vlog.close(); err == nil {
+		err = e
+	}
+	if e := lstm.wal.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,355 @@
+package zendb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Constants defining the MANIFEST files' names.
+const (
+	currentName    = "CURRENT"
+	manifestPrefix = "MANIFEST-"
+	tmpSuffix      = ".tmp"
+)
+
+// Tags of the fields of an encoded version edit.
+const (
+	tagNextFile      = 'n'
+	tagLastSeq       = 's'
+	tagAddFile       = 'a'
+	tagRemoveFile    = 'r'
+	tagDiscard       = 'd'
+	tagRemoveSegment = 'x'
+)
+
+// crcTable is the CRC32C (Castagnoli) table used by the checksums.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// versionEdit describes a change of the live SST set, and of the bytes of the value log segments that are no longer
+// pointed to. The MANIFEST is the log of these edits.
+type versionEdit struct {
+	added           []*fileMeta      // Files made live by the edit
+	removed         []*fileMeta      // Files made obsolete by the edit, only their level and number are recorded
+	nextFile        int              // Number given to the next SST file
+	lastSeq         uint64           // Sequence number of the last write when the edit was made
+	discards        map[uint32]int64 // Bytes of each value log segment the edit stopped pointing to
+	removedSegments []uint32         // Value log segments deleted by the garbage collector, whose bytes are forgotten
+}
+
+// encode encodes the edit as a sequence of tagged fields.
+func (e *versionEdit) encode() []byte {
+	buf := []byte{tagNextFile}
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(e.nextFile))
+	buf = append(buf, tagLastSeq)
+	buf = binary.LittleEndian.AppendUint64(buf, e.lastSeq)
+	for _, f := range e.removed {
+		buf = append(buf, tagRemoveFile)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(f.level))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(f.num))
+	}
+	for _, f := range e.added {
+		buf = append(buf, tagAddFile)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(f.level))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(f.num))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(f.size))
+		buf = appendLongString(buf, f.smallest)
+		buf = appendLongString(buf, f.largest)
+	}
+	for _, num := range e.removedSegments {
+		buf = append(buf, tagRemoveSegment)
+		buf = binary.LittleEndian.AppendUint32(buf, num)
+	}
+	segments := make([]uint32, 0, len(e.discards))
+	for num := range e.discards {
+		segments = append(segments, num)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	for _, num := range segments {
+		buf = append(buf, tagDiscard)
+		buf = binary.LittleEndian.AppendUint32(buf, num)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.discards[num]))
+	}
+	return buf
+}
+
+// decodeVersionEdit decodes an edit encoded by encode.
+func decodeVersionEdit(data []byte) (*versionEdit, error) {
+	e := &versionEdit{}
+	r := &byteReader{data: data}
+	for !r.done() {
+		switch tag := r.byte(); tag {
+		case tagNextFile:
+			e.nextFile = int(r.uint64())
+		case tagLastSeq:
+			e.lastSeq = r.uint64()
+		case tagRemoveFile:
+			e.removed = append(e.removed, &fileMeta{level: int(r.uint16()), num: int(r.uint64())})
+		case tagAddFile:
+			f := &fileMeta{level: int(r.uint16()), num: int(r.uint64()), size: int64(r.uint64())}
+			f.smallest = r.longString()
+			f.largest = r.longString()
+			e.added = append(e.added, f)
+		case tagRemoveSegment:
+			e.removedSegments = append(e.removedSegments, r.uint32())
+		case tagDiscard:
+			if e.discards == nil {
+				e.discards = make(map[uint32]int64)
+			}
+			num := r.uint32()
+			e.discards[num] += int64(r.uint64())
+		default:
+			return nil, ErrFileNotEncodedProperly
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	return e, nil
+}
+
+// appendLongString appends a string preceded by its length on 4 bytes.
+func appendLongString(buf []byte, s string) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+// byteReader decodes little endian fields from a byte slice, remembering the first error.
+type byteReader struct {
+	data []byte
+	err  error
+}
+
+// done checks if every byte was read, or if reading failed.
+func (r *byteReader) done() bool {
+	return len(r.data) == 0 || r.err != nil
+}
+
+// next returns the next n bytes.
+func (r *byteReader) next(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = ErrFileNotEncodedProperly
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *byteReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *byteReader) uint16() uint16 {
+	return binary.LittleEndian.Uint16(r.next(2))
+}
+
+func (r *byteReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+func (r *byteReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+// entryString reads a string encoded by appendString.
+func (r *byteReader) entryString() string {
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 || n > uint64(len(r.data)-size) {
+		r.err = ErrFileNotEncodedProperly
+	}
+	if r.err != nil {
+		return ""
+	}
+	r.data = r.data[size:]
+	return string(r.next(int(n)))
+}
+
+func (r *byteReader) longString() string {
+	n := r.uint32()
+	if r.err != nil {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+// manifest is the append-only log of the version edits, the source of truth for the live SST set.
+type manifest struct {
+	file *os.File
+	name string
+}
+
+// log appends the edit to the MANIFEST and flushes immediately.
+// A record is the length and CRC32C of the encoded edit, on 4 bytes each, followed by the edit.
+func (m *manifest) log(e *versionEdit) error {
+	data := e.encode()
+	record := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+	record = binary.LittleEndian.AppendUint32(record, crc32.Checksum(data, crcTable))
+	record = append(record, data...)
+	if _, err := m.file.Write(record); err != nil {
+		return err
+	}
+	return m.file.Sync()
+}
+
+// createManifest starts a new MANIFEST holding the given levels and discarded bytes, then atomically points CURRENT
+// to it.
+func createManifest(dir string, num int, levels [][]*fileMeta, discards map[uint32]int64, nextFile int, lastSeq uint64) (*manifest, error) {
+	name := manifestPrefix + strconv.Itoa(num)
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, FilePermission)
+	if err != nil {
+		return nil, err
+	}
+	m := &manifest{file: file, name: name}
+	snapshot := &versionEdit{nextFile: nextFile, lastSeq: lastSeq, discards: discards}
+	for _, files := range levels {
+		snapshot.added = append(snapshot.added, files...)
+	}
+	if err := m.log(snapshot); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := setCurrent(dir, name); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// setCurrent atomically replaces CURRENT by a file holding the name of the MANIFEST.
+func setCurrent(dir, name string) error {
+	return writeFileAtomic(filepath.Join(dir, currentName), func(file *os.File) error {
+		_, err := file.Write([]byte(name + "\n"))
+		return err
+	})
+}
+
+// loadManifest replays the MANIFEST pointed to by CURRENT, and returns the levels, the discarded bytes of the value
+// log segments, the next free file number and the sequence number of the last flushed write. MANIFESTs written
+// before sequence numbers existed give 0.
+// It returns an error satisfying errors.Is(err, os.ErrNotExist) when the directory has no CURRENT file.
+func loadManifest(dir string, maxLevels int) ([][]*fileMeta, map[uint32]int64, int, uint64, error) {
+	current, err := os.ReadFile(filepath.Join(dir, currentName))
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	name := strings.TrimSpace(string(current))
+	if !strings.HasPrefix(name, manifestPrefix) {
+		return nil, nil, 0, 0, fmt.Errorf("%s: %w", currentName, ErrFileNotRecognized)
+	}
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		// CURRENT exists, so a missing MANIFEST must not be mistaken for a new database.
+		return nil, nil, 0, 0, fmt.Errorf("%s: %v", name, err)
+	}
+	defer file.Close()
+
+	levels := make([][]*fileMeta, maxLevels)
+	discards := make(map[uint32]int64)
+	nextFile := 1
+	var lastSeq uint64
+	header := make([]byte, 8)
+	for {
+		// A torn record at the tail was never acknowledged, so it is ignored.
+		if _, err := io.ReadFull(file, header); err != nil {
+			break
+		}
+		data := make([]byte, binary.LittleEndian.Uint32(header[:4]))
+		if _, err := io.ReadFull(file, data); err != nil {
+			break
+		}
+		if crc32.Checksum(data, crcTable) != binary.LittleEndian.Uint32(header[4:]) {
+			return nil, nil, 0, 0, fmt.Errorf("%s: %w", name, ErrCorruptFile)
+		}
+		e, err := decodeVersionEdit(data)
+		if err != nil {
+			return nil, nil, 0, 0, fmt.Errorf("%s: %w", name, err)
+		}
+		levels = applyEdit(levels, e)
+		applyDiscards(discards, e)
+		nextFile = e.nextFile
+		lastSeq = max(lastSeq, e.lastSeq)
+	}
+	return levels, discards, nextFile, lastSeq, nil
+}
+
+// applyEdit installs the edit in the levels. Files added to level 0 by an edit that also removes level 0 files
+// take the place of the removed ones, so that level 0 stays ordered from the oldest to the newest file.
+func applyEdit(levels [][]*fileMeta, e *versionEdit) [][]*fileMeta {
+	pos := -1
+	for _, r := range e.removed {
+		if r.level >= len(levels) {
+			continue
+		}
+		files := levels[r.level]
+		for i, f := range files {
+			if f.num == r.num {
+				levels[r.level] = append(files[:i:i], files[i+1:]...)
+				if r.level == 0 && (pos < 0 || i < pos) {
+					pos = i
+				}
+				break
+			}
+		}
+	}
+	for _, f := range e.added {
+		for f.level >= len(levels) {
+			levels = append(levels, nil)
+		}
+		if f.level == 0 && pos >= 0 {
+			files := levels[0]
+			levels[0] = append(append(append([]*fileMeta(nil), files[:pos]...), f), files[pos:]...)
+			pos++
+		} else {
+			levels[f.level] = append(levels[f.level], f)
+		}
+		if f.level > 0 {
+			sortByKey(levels[f.level])
+		}
+	}
+	return levels
+}
+
+// applyDiscards adds the discarded bytes of the edit to the ones of each value log segment, after forgetting the
+// segments the edit removed.
+func applyDiscards(discards map[uint32]int64, e *versionEdit) {
+	for _, num := range e.removedSegments {
+		delete(discards, num)
+	}
+	for num, n := range e.discards {
+		discards[num] += n
+	}
+}
+
+// removeObsoleteFiles deletes the SST files that are not live, the older MANIFESTs and the temporary files.
+// Files not created by the database are left alone.
+func removeObsoleteFiles(dir string, levels [][]*fileMeta, manifestName string) error {
+	live := make(map[string]bool)
+	for _, files := range levels {
+		for _, f := range files {
+			live[sstName(f.num, f.level)] = true
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		obsolete := strings.HasSuffix(name, tmpSuffix) ||
+			(sstNameRegex.MatchString(name) && !live[name]) ||
+			(strings.HasPrefix(name, manifestPrefix) && name != manifestName)
+		if obsolete {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
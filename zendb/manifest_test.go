@@ -0,0 +1,124 @@
+package zendb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestVersionEditEncoding tests that an edit survives encoding and decoding.
+func TestVersionEditEncoding(t *testing.T) {
+	e := &versionEdit{
+		added:    []*fileMeta{{num: 7, level: 1, size: 120, smallest: "apple", largest: "pear"}},
+		removed:  []*fileMeta{{num: 3, level: 0}, {num: 4, level: 0}},
+		nextFile: 8,
+		lastSeq:  42,
+	}
+	d, err := decodeVersionEdit(e.encode())
+	if err != nil {
+		t.Fatalf("Error decoding version edit: %v", err)
+	}
+	if d.nextFile != 8 || d.lastSeq != 42 || len(d.removed) != 2 || d.removed[1].num != 4 || len(d.added) != 1 {
+		t.Fatalf("Decoded edit does not match: %+v", d)
+	}
+	if f := d.added[0]; *f != *e.added[0] {
+		t.Errorf("Decoded file does not match: %+v", f)
+	}
+	data := e.encode()
+	if _, err := decodeVersionEdit(data[:len(data)-3]); err == nil {
+		t.Errorf("Expected an error decoding a truncated edit")
+	}
+}
+
+// TestApplyEditKeepsLevel0Order tests that a merged level 0 file takes the place of its inputs.
+func TestApplyEditKeepsLevel0Order(t *testing.T) {
+	levels := make([][]*fileMeta, 2)
+	for num := 1; num <= 4; num++ {
+		levels = applyEdit(levels, &versionEdit{added: []*fileMeta{{num: num}}})
+	}
+	levels = applyEdit(levels, &versionEdit{
+		removed: []*fileMeta{{num: 2}, {num: 3}},
+		added:   []*fileMeta{{num: 5}},
+	})
+	var order []int
+	for _, f := range levels[0] {
+		order = append(order, f.num)
+	}
+	if len(order) != 3 || order[0] != 1 || order[1] != 5 || order[2] != 4 {
+		t.Errorf("Expected level 0 order [1 5 4], got %v", order)
+	}
+}
+
+// TestManifestRecovery tests that the live set and the discarded bytes come from the MANIFEST, ignoring a torn tail.
+func TestManifestRecovery(t *testing.T) {
+	dir := t.TempDir()
+	levels := [][]*fileMeta{{{num: 1, smallest: "a", largest: "c"}}, nil}
+	m, err := createManifest(dir, 2, levels, map[uint32]int64{1: 100, 2: 50}, 3, 10)
+	if err != nil {
+		t.Fatalf("Error creating MANIFEST: %v", err)
+	}
+	err = m.log(&versionEdit{
+		removed:  []*fileMeta{{num: 1}},
+		added:    []*fileMeta{{num: 3, level: 1, smallest: "a", largest: "c"}},
+		nextFile: 4,
+		lastSeq:  12,
+		discards: map[uint32]int64{2: 25, 3: 10},
+	})
+	if err != nil {
+		t.Fatalf("Error logging edit: %v", err)
+	}
+	err = m.log(&versionEdit{removedSegments: []uint32{1}, nextFile: 4, lastSeq: 12})
+	if err != nil {
+		t.Fatalf("Error logging edit: %v", err)
+	}
+	m.file.Write([]byte{40, 0, 0, 0, 1, 2}) // Torn record
+	m.file.Close()
+
+	levels, discards, nextFile, lastSeq, err := loadManifest(dir, 2)
+	if err != nil {
+		t.Fatalf("Error loading MANIFEST: %v", err)
+	}
+	if nextFile != 4 || lastSeq != 12 || len(levels[0]) != 0 || len(levels[1]) != 1 || levels[1][0].num != 3 {
+		t.Errorf("Unexpected recovered state: next file %d, levels %v", nextFile, levels)
+	}
+	if len(discards) != 2 || discards[2] != 75 || discards[3] != 10 {
+		t.Errorf("Expected 75 and 10 bytes discarded from segments 2 and 3, got %v", discards)
+	}
+
+	if _, _, _, _, err := loadManifest(t.TempDir(), 2); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing CURRENT to be reported, got %v", err)
+	}
+}
+
+// TestOpenIgnoresUnlistedFiles tests that SST files missing from the MANIFEST are never treated as live.
+func TestOpenIgnoresUnlistedFiles(t *testing.T) {
+	dir := t.TempDir()
+	lstm, err := Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	if err := lstm.Set([]byte("key"), []byte("a value long enough to be flushed")); err != nil {
+		t.Errorf("Error setting key-value pair: %v", err)
+	}
+	lstm.Close()
+
+	// A half-written file left by a crash, and a file the database does not own.
+	sstDir := filepath.Join(dir, DefaultSSTDir)
+	os.WriteFile(filepath.Join(sstDir, sstName(99, 0)), []byte("zeni"), FilePermission)
+	os.WriteFile(filepath.Join(sstDir, "notes.txt"), []byte("keep me"), FilePermission)
+
+	lstm, err = Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Error reopening Lstm: %v", err)
+	}
+	defer lstm.Close()
+	if v, err := lstm.Get([]byte("key")); err != nil || string(v) != "a value long enough to be flushed" {
+		t.Errorf("Unexpected value after reopening: %s (%v)", v, err)
+	}
+	if _, err := os.Stat(filepath.Join(sstDir, sstName(99, 0))); !os.IsNotExist(err) {
+		t.Errorf("Expected the unlisted SST file to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sstDir, "notes.txt")); err != nil {
+		t.Errorf("Expected the foreign file to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package zendb
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// MemTableType selects the sorted table holding the versions of the memtables.
+type MemTableType int
+
+const (
+	// RedBlackTreeMemTable keeps the versions in a red-black tree, which is not read while it is written.
+	RedBlackTreeMemTable MemTableType = iota
+	// SkipListMemTable keeps the versions in a concurrent skiplist, which readers use while a write inserts into it.
+	SkipListMemTable
+)
+
+// sortedTable holds the versions of the keys of a memtable, sorted by key, then from the newest to the oldest.
+type sortedTable interface {
+	put(p Pair) int                           // Inserts a version and returns the memory it added
+	find(key string, seq uint64) (Pair, bool) // Returns the newest version of the key as of the sequence number
+	Traverse() []Pair                         // Returns the versions in order
+	Len() int                                 // Returns the number of versions
+	concurrent() bool                         // Reports whether the table may be read while it is written
+	iterator() internalIterator               // Walks the versions in place, once the table is no longer written
+}
+
+// MemTable represents an in-memory table.
+type MemTable struct {
+	table sortedTable
+	size  int // Approximate memory taken by the versions, nodes included
+}
+
+// Set adds a new key-value pair to the in-memory table.
+func (mem *MemTable) Set(key, value string) error {
+	mem.size += mem.table.put(Pair{marker: true, key: key, value: value})
+	return nil
+}
+
+// Get retrieves the value associated with a key from the in-memory table.
+func (mem *MemTable) Get(key string) (string, error) {
+	p, err := mem.getAt(key, math.MaxUint64)
+	return p.value, err
+}
+
+// getAt retrieves the version of a key seen as of the sequence number.
+func (mem *MemTable) getAt(key string, seq uint64) (Pair, error) {
+	p, ok := mem.table.find(key, seq)
+	if !ok {
+		return Pair{}, ErrKeyNotFound
+	}
+	if p.marker {
+		return p, nil
+	}
+	return p, ErrKeyDeleted
+}
+
+// Del removes a key from the in-memory table.
+func (mem *MemTable) Del(key string) error {
+	p := Pair{marker: false, key: key, value: ""}
+	mem.size += mem.table.put(p)
+	return nil
+}
+
+// put inserts a version of a key. The table keeps every version, the pairs without a sequence number,
+// written before sequence numbers existed, replace each other.
+func (mem *MemTable) put(p Pair) {
+	mem.size += mem.table.put(p)
+}
+
+// Flush writes the contents of the in-memory table to a file.
+func (mem *MemTable) Flush(fileName string) error {
+	return writeSST(fileName, mem.table.Traverse(), DefaultOptions())
+}
+
+// writeSST atomically writes the sorted pairs to a new SST file.
+func writeSST(fileName string, kv []Pair, opts *Options) error {
+	return writeFileAtomic(fileName, func(file *os.File) error {
+		return encodeSST(file, kv, opts)
+	})
+}
+
+// encodeSST writes the sorted pairs in the block-based SST format: the header, the data blocks, the filter block,
+// the index block holding the first and last keys and the location of each data block, then the footer.
+// The header, each data block, the filter block and the index block are followed by their CRC32C.
+func encodeSST(file *os.File, kv []Pair, opts *Options) error {
+	// The legacy bloom filter is left empty, it only keeps the version at the same offset as before.
+	header := append([]byte(MAGIC), binary.LittleEndian.AppendUint32(nil, uint32(len(kv)))...)
+	header = append(header, make([]byte, BloomLength)...)
+	header = binary.LittleEndian.AppendUint16(header, sstVersion)
+	if _, err := file.Write(appendChecksum(header)); err != nil {
+		return err
+	}
+
+	var index []blockHandle
+	var block []byte
+	offset := uint64(HeaderSize + ChecksumSize)
+	for i, p := range kv {
+		if len(block) == 0 {
+			index = append(index, blockHandle{firstKey: p.key, offset: offset})
+		}
+		block = append(block, encodeEntry(p)...)
+		if len(block) >= opts.BlockSize || i == len(kv)-1 {
+			h := &index[len(index)-1]
+			h.lastKey, h.size = p.key, uint32(len(block))
+			if _, err := file.Write(appendChecksum(block)); err != nil {
+				return err
+			}
+			offset += uint64(len(block) + ChecksumSize)
+			block = block[:0]
+		}
+	}
+
+	filterBlock := GetBloom(kv, opts.BloomBitsPerKey).Encode()
+	if _, err := file.Write(appendChecksum(filterBlock)); err != nil {
+		return err
+	}
+	var indexBlock []byte
+	for _, h := range index {
+		indexBlock = append(indexBlock, h.encode()...)
+	}
+	if _, err := file.Write(appendChecksum(indexBlock)); err != nil {
+		return err
+	}
+	f := footer{
+		indexOffset:  offset + uint64(len(filterBlock)+ChecksumSize),
+		indexSize:    uint32(len(indexBlock)),
+		filterOffset: offset,
+		filterSize:   uint32(len(filterBlock)),
+	}
+	_, err := file.Write(f.encode())
+	return err
+}
+
+// appendChecksum appends the CRC32C of the data to it.
+func appendChecksum(data []byte) []byte {
+	return binary.LittleEndian.AppendUint32(data, crc32.Checksum(data, crcTable))
+}
+
+// encodeEntry encodes a pair as a set, expiring set or deletion mark, followed by the sequence number, the expiry
+// time of an expiring set, then by the key and, for a set, the value, with their lengths. The mark of a set is
+// uppercase when its value is a pointer into the value log.
+func encodeEntry(p Pair) []byte {
+	entry := []byte("d")
+	if p.marker {
+		entry = []byte("s")
+	}
+	expires := p.marker && p.expires != 0
+	if expires {
+		entry = []byte("t")
+	}
+	if p.marker && p.indirect {
+		entry[0] -= 'a' - 'A'
+	}
+	entry = binary.LittleEndian.AppendUint64(entry, p.seq)
+	if expires {
+		entry = binary.LittleEndian.AppendUint64(entry, uint64(p.expires))
+	}
+	entry = appendString(entry, p.key)
+	if p.marker {
+		entry = appendString(entry, p.value)
+	}
+	return entry
+}
+
+// writeFileAtomic writes a file under a temporary name, flushes it to the disk, then renames it into place.
+// Once it returns, either the whole file is durable under its name or the name is left untouched.
+func writeFileAtomic(fileName string, write func(file *os.File) error) error {
+	tmp := fileName + tmpSuffix
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, fileName)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(fileName))
+}
+
+// syncDir flushes the directory entries, making renames and creations durable. Windows denies syncing a directory,
+// NTFS journaling the renames itself, so it is a no-op there.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// NewMemTable creates a new in-memory table, backed by a red-black tree.
+func NewMemTable() *MemTable {
+	return newMemTable(RedBlackTreeMemTable)
+}
+
+// newMemTable creates a new in-memory table of the type.
+func newMemTable(typ MemTableType) *MemTable {
+	if typ == SkipListMemTable {
+		return &MemTable{table: newSkipList()}
+	}
+	return &MemTable{table: &redBlackTree{}}
+}
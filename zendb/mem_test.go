@@ -1,8 +1,10 @@
-package main
+package zendb
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -74,3 +76,37 @@ func TestMemTableFlush(t *testing.T) {
 	// Clean up test file
 	defer os.Remove(fileName)
 }
+
+// TestWriteFileAtomic tests that a failed write leaves the previous file untouched.
+func TestWriteFileAtomic(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "atomic.sst")
+	if err := os.WriteFile(fileName, []byte("old"), FilePermission); err != nil {
+		t.Fatalf("Error creating test file: %v", err)
+	}
+
+	failure := errors.New("disk full")
+	err := writeFileAtomic(fileName, func(file *os.File) error {
+		file.Write([]byte("half"))
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+	if data, _ := os.ReadFile(fileName); string(data) != "old" {
+		t.Errorf("Expected the previous content to be kept, got %q", data)
+	}
+	if _, err := os.Stat(fileName + tmpSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+
+	err = writeFileAtomic(fileName, func(file *os.File) error {
+		_, err := file.Write([]byte("new"))
+		return err
+	})
+	if err != nil {
+		t.Errorf("Error writing file: %v", err)
+	}
+	if data, _ := os.ReadFile(fileName); string(data) != "new" {
+		t.Errorf("Expected the new content, got %q", data)
+	}
+}
@@ -0,0 +1,102 @@
+package zendb
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// memoryBudget accounts for the memory of the memtables of every open database of the process. Once the active
+// memtables take too much of the budget, the write that noticed it freezes its own memtable and asks the other
+// databases to freeze theirs, so that their flushes give the memory back.
+type memoryBudget struct {
+	limit  atomic.Int64 // Bytes of the budget, 0 when there is none
+	active atomic.Int64 // Bytes of the active memtables
+	total  atomic.Int64 // Bytes of the active and frozen memtables
+	mu     sync.Mutex
+	dbs    map[*Lstm]struct{} // Open databases
+}
+
+// budget is the memory budget shared by the databases of the process.
+var budget = &memoryBudget{dbs: make(map[*Lstm]struct{})}
+
+// SetMemoryBudget bounds the approximate memory taken by the memtables of all the open databases, the frozen ones
+// waiting for their flush included. A database flushes once its memtable reaches its own FlushThreshold, or
+// earlier when the budget runs short. A budget of 0, the default, removes the bound.
+func SetMemoryBudget(bytes int64) {
+	budget.limit.Store(max(bytes, 0))
+}
+
+// MemoryUsage returns the approximate memory taken by the memtables of all the open databases, in bytes.
+func MemoryUsage() int64 {
+	return budget.total.Load()
+}
+
+// register adds an open database whose active memtable already holds size bytes.
+func (b *memoryBudget) register(lstm *Lstm, size int) {
+	b.mu.Lock()
+	b.dbs[lstm] = struct{}{}
+	b.mu.Unlock()
+	b.grow(size)
+}
+
+// unregister removes a closed database along with the memory of its memtables.
+func (b *memoryBudget) unregister(lstm *Lstm, active, total int) {
+	b.mu.Lock()
+	delete(b.dbs, lstm)
+	b.mu.Unlock()
+	b.active.Add(-int64(active))
+	b.total.Add(-int64(total))
+}
+
+// grow accounts for the bytes written to an active memtable, which may be negative when values were replaced.
+func (b *memoryBudget) grow(size int) {
+	b.active.Add(int64(size))
+	b.total.Add(int64(size))
+}
+
+// freeze accounts for an active memtable being frozen.
+func (b *memoryBudget) freeze(size int) {
+	b.active.Add(-int64(size))
+}
+
+// release accounts for a frozen memtable being flushed.
+func (b *memoryBudget) release(size int) {
+	b.total.Add(-int64(size))
+}
+
+// exceeded checks if the memtables should be frozen: the active ones take most of the budget, or the memtables take
+// all of it and the active ones at least half, the flushes of the frozen ones alone not being enough to make room.
+func (b *memoryBudget) exceeded() bool {
+	limit := b.limit.Load()
+	if limit == 0 {
+		return false
+	}
+	active := b.active.Load()
+	return active >= limit/8*7 || (b.total.Load() >= limit && active >= limit/2)
+}
+
+// requestFreeze asks the open databases other than the given one to freeze their active memtable.
+func (b *memoryBudget) requestFreeze(except *Lstm) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for lstm := range b.dbs {
+		if lstm != except {
+			lstm.freezeRequested.Store(true)
+			lstm.triggerFlush()
+		}
+	}
+}
+
+// freezeOnRequest freezes the active memtable if the memory budget asked for it. A write holding writeMu freezes
+// it instead when it ends, so the flush goroutine does not wait for it, a stalled write waiting on the flush.
+func (lstm *Lstm) freezeOnRequest() {
+	if !lstm.freezeRequested.Load() || !lstm.writeMu.TryLock() {
+		return
+	}
+	defer lstm.writeMu.Unlock()
+	lstm.mu.Lock()
+	defer lstm.mu.Unlock()
+	if !lstm.closed {
+		lstm.memFlush()
+	}
+}
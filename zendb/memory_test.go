@@ -0,0 +1,95 @@
+package zendb
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// TestMemTableFootprint tests that both memtable types account for their nodes besides the keys and the values,
+// and that a replaced value gives its memory back.
+func TestMemTableFootprint(t *testing.T) {
+	for _, typ := range []MemTableType{RedBlackTreeMemTable, SkipListMemTable} {
+		mem := newMemTable(typ)
+		data := 0
+		for i := 0; i < 100; i++ {
+			p := Pair{marker: true, key: fmt.Sprintf("key%03d", i), value: fmt.Sprintf("value%d", i), seq: uint64(i + 1)}
+			mem.put(p)
+			data += len(p.key) + len(p.value)
+		}
+		// Every node holds at least its pair.
+		if least := data + 100*int(unsafe.Sizeof(Pair{})); mem.size < least {
+			t.Errorf("Type %d: expected at least %d bytes, got %d", typ, least, mem.size)
+		}
+	}
+
+	mem := NewMemTable()
+	mem.Set("key", "a long value")
+	size := mem.size
+	mem.Set("key", "short")
+	if mem.size != size-len("a long value")+len("short") {
+		t.Errorf("Expected the replaced value to give its bytes back, got %d then %d", size, mem.size)
+	}
+}
+
+// TestMemoryBudget tests that the memory budget freezes the memtables of every open database before they reach
+// their flush threshold, and that closing them gives their memory back.
+func TestMemoryBudget(t *testing.T) {
+	const limit = 16 << 10
+	SetMemoryBudget(limit)
+	defer SetMemoryBudget(0)
+	usage := MemoryUsage()
+
+	opts := &Options{FlushThreshold: 1 << 20, CompactionThreshold: 100}
+	idle, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	busy, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	value := bytes.Repeat([]byte("v"), 100)
+	for i := 0; i < 20; i++ {
+		idle.Set([]byte(fmt.Sprintf("key%02d", i)), value)
+	}
+	if used := MemoryUsage() - usage; used < 20*int64(len(value)) || used >= limit/2 {
+		t.Errorf("Expected the idle database to use a few kilobytes, got %d bytes", used)
+	}
+	for i := 0; i < 200; i++ {
+		if err := busy.Set([]byte(fmt.Sprintf("key%03d", i)), value); err != nil {
+			t.Errorf("Error setting key-value pair: %v", err)
+		}
+	}
+	// The idle database freezes its memtable in the background.
+	for _, lstm := range []*Lstm{idle, busy} {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			lstm.mu.RLock()
+			flushed := len(lstm.levels[0])
+			lstm.mu.RUnlock()
+			if flushed > 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the memory budget to flush both databases")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		waitForFlush(t, lstm)
+	}
+	if used := MemoryUsage() - usage; used >= limit {
+		t.Errorf("Expected the memtables to stay within the budget of %d bytes, got %d", limit, used)
+	}
+	if v, err := idle.Get([]byte("key07")); err != nil || !bytes.Equal(v, value) {
+		t.Errorf("Expected the flushed value, got %q (%v)", v, err)
+	}
+
+	idle.Close()
+	busy.Close()
+	if used := MemoryUsage() - usage; used != 0 {
+		t.Errorf("Expected the closed databases to give their memory back, got %d bytes", used)
+	}
+}
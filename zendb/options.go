@@ -0,0 +1,179 @@
+package zendb
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// Default values used when an Options field is left empty.
+const (
+	DefaultFlushThreshold      = 4 << 20
+	DefaultCompactionThreshold = 5
+	DefaultBaseLevelSize       = flushesPerBaseLevel * DefaultFlushThreshold
+	DefaultLevelSizeMultiplier = 10
+	DefaultMaxLevels           = 7
+	DefaultTargetFileSize      = DefaultFlushThreshold / filesPerFlush
+	DefaultBucketThreshold     = 4
+	DefaultBlockSize           = 4 << 10
+	DefaultBloomBitsPerKey     = 10
+	DefaultMaxKeySize          = 64 << 10
+	DefaultMaxValueSize        = 16 << 20
+	DefaultValueLogFileSize    = 1 << 20
+	DefaultValueLogGCRatio     = 0.5
+	DefaultImmutableSlowdown   = 3
+	DefaultImmutableStop       = 6
+	DefaultSlowdownDelay       = time.Millisecond
+	DefaultSSTDir              = "Zen_SST"
+	DefaultValueLogDir         = "Zen_VLOG"
+	DefaultWALName             = "log.wal"
+)
+
+// Size targets of level 1 and of the files written by compaction, relative to the flush threshold: level 1 holds
+// ten flushes, and a flush fills two files.
+const (
+	flushesPerBaseLevel = 10
+	filesPerFlush       = 2
+)
+
+// Options configures a database opened with Open.
+type Options struct {
+	FlushThreshold      int    // Approximate memory in bytes of the memtable before flushing
+	CompactionThreshold int    // Number of level 0 SST files before starting to compact
+	SSTDir              string // Directory holding the SST files
+	WALPath             string // Path of the Write-Ahead Log
+
+	BaseLevelSize       int64 // Size target in bytes of level 1, ten times the flush threshold by default
+	LevelSizeMultiplier int   // Ratio between the size targets of a level and the level above it
+	MaxLevels           int   // Number of levels, including level 0
+	TargetFileSize      int64 // Size in bytes of the compaction output files, half the flush threshold by default
+
+	BlockSize       int // Size in bytes at which an SST data block is closed
+	BloomBitsPerKey int // Bits of the SST bloom filters per key, 10 gives about 1% of false positives
+
+	MaxKeySize   int // Size in bytes of the largest key accepted by a write
+	MaxValueSize int // Size in bytes of the largest value accepted by a write
+
+	// ValueThreshold is the size in bytes from which a flushed value is moved to the value log, the SST files
+	// keeping a pointer to it. The value log is not used when it is 0.
+	ValueThreshold   int
+	ValueLogDir      string  // Directory holding the value log segments
+	ValueLogFileSize int64   // Size in bytes at which a value log segment is sealed
+	ValueLogGCRatio  float64 // Share of live bytes below which the garbage collector rewrites a sealed segment
+
+	MemTableType    MemTableType    // Sorted table of the memtables, a red-black tree by default
+	WALRecoveryMode WALRecoveryMode // Handling of the torn or corrupt WAL records, a corrupt tail is dropped by default
+	CompactionStyle CompactionStyle // Compaction strategy, leveled by default
+	BucketThreshold int             // Number of similar sized files merged by size-tiered compaction
+
+	// A write is delayed by SlowdownDelay once the frozen memtables or the level 0 files waiting for a compaction
+	// reach their slowdown trigger, and waits for the flush and the compaction once they reach their stop trigger.
+	// The level 0 triggers default to 2 and 3 times the CompactionThreshold.
+	ImmutableSlowdownTrigger int
+	ImmutableStopTrigger     int
+	L0SlowdownTrigger        int
+	L0StopTrigger            int
+	SlowdownDelay            time.Duration
+	WriteStallTimeout        time.Duration // Longest wait of a stopped write, which then fails with ErrWriteStall, no limit when 0
+
+	// OnBackgroundError is called with the errors raised by background work such as flushes and compactions.
+	// If nil, these errors are discarded.
+	OnBackgroundError func(error)
+}
+
+// DefaultOptions returns the options used when Open is given nil.
+func DefaultOptions() *Options {
+	return &Options{
+		FlushThreshold:           DefaultFlushThreshold,
+		CompactionThreshold:      DefaultCompactionThreshold,
+		BaseLevelSize:            DefaultBaseLevelSize,
+		LevelSizeMultiplier:      DefaultLevelSizeMultiplier,
+		MaxLevels:                DefaultMaxLevels,
+		TargetFileSize:           DefaultTargetFileSize,
+		BucketThreshold:          DefaultBucketThreshold,
+		BlockSize:                DefaultBlockSize,
+		BloomBitsPerKey:          DefaultBloomBitsPerKey,
+		MaxKeySize:               DefaultMaxKeySize,
+		MaxValueSize:             DefaultMaxValueSize,
+		ValueLogFileSize:         DefaultValueLogFileSize,
+		ValueLogGCRatio:          DefaultValueLogGCRatio,
+		ImmutableSlowdownTrigger: DefaultImmutableSlowdown,
+		ImmutableStopTrigger:     DefaultImmutableStop,
+		L0SlowdownTrigger:        2 * DefaultCompactionThreshold,
+		L0StopTrigger:            3 * DefaultCompactionThreshold,
+		SlowdownDelay:            DefaultSlowdownDelay,
+	}
+}
+
+// withDefaults returns a copy of the options with the empty fields filled in, relative to dir.
+func (opts *Options) withDefaults(dir string) *Options {
+	o := DefaultOptions()
+	if opts != nil {
+		*o = *opts
+	}
+	if o.FlushThreshold <= 0 {
+		o.FlushThreshold = DefaultFlushThreshold
+	}
+	if o.CompactionThreshold <= 1 {
+		o.CompactionThreshold = DefaultCompactionThreshold
+	}
+	// The size targets follow the flush threshold, so that compacting level 0 does not overflow level 1 at once.
+	if o.BaseLevelSize <= 0 {
+		o.BaseLevelSize = flushesPerBaseLevel * int64(o.FlushThreshold)
+	}
+	if o.LevelSizeMultiplier <= 1 {
+		o.LevelSizeMultiplier = DefaultLevelSizeMultiplier
+	}
+	if o.MaxLevels < 2 {
+		o.MaxLevels = DefaultMaxLevels
+	}
+	if o.TargetFileSize <= 0 {
+		o.TargetFileSize = int64(o.FlushThreshold) / filesPerFlush
+	}
+	if o.BucketThreshold < 2 {
+		o.BucketThreshold = DefaultBucketThreshold
+	}
+	if o.BlockSize <= 0 {
+		o.BlockSize = DefaultBlockSize
+	}
+	if o.BloomBitsPerKey <= 0 {
+		o.BloomBitsPerKey = DefaultBloomBitsPerKey
+	}
+	if o.MaxKeySize <= 0 {
+		o.MaxKeySize = DefaultMaxKeySize
+	}
+	if o.MaxValueSize <= 0 {
+		o.MaxValueSize = DefaultMaxValueSize
+	}
+	if o.ValueLogFileSize <= 0 {
+		o.ValueLogFileSize = DefaultValueLogFileSize
+	}
+	if o.ValueLogGCRatio <= 0 || o.ValueLogGCRatio > 1 {
+		o.ValueLogGCRatio = DefaultValueLogGCRatio
+	}
+	if o.ImmutableSlowdownTrigger <= 0 {
+		o.ImmutableSlowdownTrigger = DefaultImmutableSlowdown
+	}
+	if o.ImmutableStopTrigger <= 0 {
+		o.ImmutableStopTrigger = DefaultImmutableStop
+	}
+	if o.L0SlowdownTrigger <= 0 {
+		o.L0SlowdownTrigger = 2 * o.CompactionThreshold
+	}
+	// Below the compaction threshold, level 0 would never be compacted and the writes would stop for good.
+	if o.L0StopTrigger < o.CompactionThreshold {
+		o.L0StopTrigger = 3 * o.CompactionThreshold
+	}
+	if o.SlowdownDelay <= 0 {
+		o.SlowdownDelay = DefaultSlowdownDelay
+	}
+	if o.ValueLogDir == "" {
+		o.ValueLogDir = filepath.Join(dir, DefaultValueLogDir)
+	}
+	if o.SSTDir == "" {
+		o.SSTDir = filepath.Join(dir, DefaultSSTDir)
+	}
+	if o.WALPath == "" {
+		o.WALPath = filepath.Join(dir, DefaultWALName)
+	}
+	return o
+}
@@ -0,0 +1,43 @@
+package zendb
+
+import "testing"
+
+// checkSizeTargets checks that the size targets of the levels and their files follow the size of a flush, so that
+// compacting level 0 neither overflows level 1 at once nor splits a flush into many small files.
+func checkSizeTargets(t *testing.T, opts *Options) {
+	t.Helper()
+	flush := int64(opts.FlushThreshold)
+	if l0 := int64(opts.CompactionThreshold) * flush; opts.BaseLevelSize < l0 {
+		t.Errorf("Expected level 1 to hold a compaction of level 0, %d bytes, got a target of %d", l0, opts.BaseLevelSize)
+	}
+	if files := flush / opts.TargetFileSize; files > 4 {
+		t.Errorf("Expected a flush to fill at most 4 files, got %d", files)
+	}
+	if opts.TargetFileSize*int64(opts.LevelSizeMultiplier) > opts.BaseLevelSize {
+		t.Errorf("Expected level 1 to hold %d files of %d bytes, got a target of %d",
+			opts.LevelSizeMultiplier, opts.TargetFileSize, opts.BaseLevelSize)
+	}
+	if opts.TargetFileSize < 100*int64(opts.BlockSize) {
+		t.Errorf("Expected a file to hold at least 100 blocks of %d bytes, got a target of %d", opts.BlockSize, opts.TargetFileSize)
+	}
+}
+
+// TestDefaultOptionsConsistent tests that the default size targets are consistent with each other.
+func TestDefaultOptionsConsistent(t *testing.T) {
+	checkSizeTargets(t, DefaultOptions())
+	checkSizeTargets(t, (*Options)(nil).withDefaults(t.TempDir()))
+}
+
+// TestSizeTargetsFollowFlushThreshold tests that the unset size targets are derived from the flush threshold,
+// and that the ones set are kept.
+func TestSizeTargetsFollowFlushThreshold(t *testing.T) {
+	opts := (&Options{FlushThreshold: 64 << 20}).withDefaults(t.TempDir())
+	checkSizeTargets(t, opts)
+	if opts.BaseLevelSize != 640<<20 || opts.TargetFileSize != 32<<20 {
+		t.Errorf("Expected targets of 640 MiB and 32 MiB, got %d and %d", opts.BaseLevelSize, opts.TargetFileSize)
+	}
+	opts = (&Options{FlushThreshold: 64 << 20, BaseLevelSize: 1 << 30, TargetFileSize: 8 << 20}).withDefaults(t.TempDir())
+	if opts.BaseLevelSize != 1<<30 || opts.TargetFileSize != 8<<20 {
+		t.Errorf("Expected the targets set to be kept, got %d and %d", opts.BaseLevelSize, opts.TargetFileSize)
+	}
+}
@@ -0,0 +1,131 @@
+package zendb
+
+import "sort"
+
+// Bounds of the ratio between a file's size and the average size of a size-tiered bucket.
+const (
+	bucketLow  = 0.5
+	bucketHigh = 1.5
+)
+
+// CompactionStyle selects the compaction strategy of a database.
+type CompactionStyle int
+
+const (
+	// LeveledCompaction bounds read amplification by keeping the files of each level disjoint.
+	LeveledCompaction CompactionStyle = iota
+	// SizeTieredCompaction bounds write amplification by merging files of similar size together.
+	SizeTieredCompaction
+)
+
+// compactionPicker chooses the next compaction of a compaction strategy, or returns nil when there is none.
+// Its backlog is the number of level 0 files waiting for a compaction, which the write stalls are triggered on.
+type compactionPicker interface {
+	pick(lstm *Lstm) *compaction
+	backlog(lstm *Lstm) int
+}
+
+// newCompactionPicker returns the picker implementing the compaction style.
+func newCompactionPicker(style CompactionStyle) compactionPicker {
+	if style == SizeTieredCompaction {
+		return sizeTieredPicker{}
+	}
+	return leveledPicker{}
+}
+
+// leveledPicker picks the level that exceeds its target the most.
+// Level 0 is scored by its number of files, the other levels by their size.
+type leveledPicker struct{}
+
+func (leveledPicker) pick(lstm *Lstm) *compaction {
+	best, bestScore := -1, 0.0
+	for level := 0; level < len(lstm.levels)-1; level++ {
+		var score float64
+		if level == 0 {
+			score = float64(len(lstm.levels[0])) / float64(lstm.opts.CompactionThreshold)
+		} else {
+			score = float64(levelSize(lstm.levels[level])) / float64(lstm.levelTarget(level))
+		}
+		if score >= 1 && score > bestScore {
+			best, bestScore = level, score
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	c := &compaction{level: best, outputLevel: best + 1}
+	if best == 0 {
+		// Level 0 files overlap each other, so they are all merged at once.
+		c.inputs = append([]*fileMeta(nil), lstm.levels[0]...)
+	} else {
+		// The other levels are compacted one file at a time, cycling through their key space.
+		files := lstm.levels[best]
+		i := sort.Search(len(files), func(i int) bool { return files[i].smallest > lstm.compactPointer[best] })
+		if i == len(files) {
+			i = 0
+		}
+		c.inputs = []*fileMeta{files[i]}
+	}
+	smallest, largest := keyRange(c.inputs)
+	c.overlap = lstm.overlapping(c.outputLevel, smallest, largest)
+	return c
+}
+
+// backlog counts every level 0 file, since they are all merged into level 1.
+func (leveledPicker) backlog(lstm *Lstm) int {
+	return len(lstm.levels[0])
+}
+
+// sizeTieredPicker keeps every file in level 0 and merges a bucket of files of similar size once it
+// holds BucketThreshold files. Buckets are runs of consecutive files, so that the merged file can take
+// their place in the order of level 0.
+type sizeTieredPicker struct{}
+
+func (sizeTieredPicker) pick(lstm *Lstm) *compaction {
+	files := lstm.levels[0]
+	var c *compaction
+	buckets(files, func(start, end int) bool {
+		if end-start < lstm.opts.BucketThreshold {
+			return true
+		}
+		c = &compaction{
+			inputs:      append([]*fileMeta(nil), files[start:end]...),
+			keepDeletes: start > 0,
+		}
+		return false
+	})
+	return c
+}
+
+// backlog counts the files of the buckets holding BucketThreshold files, since every file stays in level 0.
+func (sizeTieredPicker) backlog(lstm *Lstm) int {
+	n := 0
+	buckets(lstm.levels[0], func(start, end int) bool {
+		if end-start >= lstm.opts.BucketThreshold {
+			n += end - start
+		}
+		return true
+	})
+	return n
+}
+
+// buckets calls fn with the bounds of each run of consecutive files of similar size, until fn returns false.
+func buckets(files []*fileMeta, fn func(start, end int) bool) {
+	for start := 0; start < len(files); {
+		end := start + 1
+		total := files[start].size
+		for end < len(files) {
+			avg := float64(total) / float64(end-start)
+			if size := float64(files[end].size); size < avg*bucketLow || size > avg*bucketHigh {
+				break
+			}
+			total += files[end].size
+			end++
+		}
+		if !fn(start, end) {
+			return
+		}
+		start = end
+	}
+}
@@ -0,0 +1,97 @@
+package zendb
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestSizeTieredPickerBuckets tests that only runs of similar sized files are picked.
+func TestSizeTieredPickerBuckets(t *testing.T) {
+	lstm := &Lstm{opts: &Options{BucketThreshold: 3}, levels: make([][]*fileMeta, 1)}
+	for i, size := range []int64{1000, 100, 110, 90, 400} {
+		lstm.levels[0] = append(lstm.levels[0], &fileMeta{num: i + 1, size: size})
+	}
+
+	c := sizeTieredPicker{}.pick(lstm)
+	if c == nil {
+		t.Fatal("Expected a compaction to be picked")
+	}
+	if len(c.inputs) != 3 || c.inputs[0].num != 2 || c.inputs[2].num != 4 {
+		t.Errorf("Expected files 2 to 4 to be picked, got %d files starting at %d", len(c.inputs), c.inputs[0].num)
+	}
+	if !c.keepDeletes {
+		t.Errorf("Expected deletions to be kept while file 1 is older than the bucket")
+	}
+	if n := (sizeTieredPicker{}).backlog(lstm); n != 3 {
+		t.Errorf("Expected a backlog of 3 files, got %d", n)
+	}
+
+	lstm.levels[0] = lstm.levels[0][:3]
+	if c := (sizeTieredPicker{}).pick(lstm); c != nil {
+		t.Errorf("Expected no compaction, got %d files", len(c.inputs))
+	}
+	if n := (sizeTieredPicker{}).backlog(lstm); n != 0 {
+		t.Errorf("Expected no backlog, got %d files", n)
+	}
+}
+
+// TestSizeTieredCompaction tests that size-tiered compaction keeps every file in level 0 and the data readable.
+func TestSizeTieredCompaction(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{
+		FlushThreshold:  100,
+		CompactionStyle: SizeTieredCompaction,
+		BucketThreshold: 3,
+	}
+	lstm, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+
+	for i := 0; i < 600; i++ {
+		if err := lstm.Set([]byte(fmt.Sprintf("key%03d", i%200)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Errorf("Error setting key-value pair: %v", err)
+		}
+	}
+	for i := 0; i < 200; i += 4 {
+		if _, err := lstm.Del([]byte(fmt.Sprintf("key%03d", i))); err != nil {
+			t.Errorf("Error deleting key: %v", err)
+		}
+	}
+	for {
+		compacted, err := lstm.compact()
+		if err != nil {
+			t.Fatalf("Error compacting: %v", err)
+		}
+		if !compacted {
+			break
+		}
+	}
+
+	lstm.mu.RLock()
+	for level := 1; level < len(lstm.levels); level++ {
+		if len(lstm.levels[level]) != 0 {
+			t.Errorf("Expected level %d to be empty, got %d files", level, len(lstm.levels[level]))
+		}
+	}
+	lstm.mu.RUnlock()
+
+	// The order of level 0 must survive reopening.
+	if err := lstm.Close(); err != nil {
+		t.Fatalf("Error closing Lstm: %v", err)
+	}
+	if lstm, err = Open(dir, opts); err != nil {
+		t.Fatalf("Error reopening Lstm: %v", err)
+	}
+	defer lstm.Close()
+	for i := 0; i < 200; i++ {
+		v, err := lstm.Get([]byte(fmt.Sprintf("key%03d", i)))
+		if i%4 == 0 {
+			if err == nil {
+				t.Errorf("Expected key%03d to be deleted, got %s", i, v)
+			}
+		} else if err != nil || string(v) != fmt.Sprintf("value%d", i+400) {
+			t.Errorf("Expected value%d for key%03d, got %s (%v)", i+400, i, v, err)
+		}
+	}
+}
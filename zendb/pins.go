@@ -0,0 +1,61 @@
+package zendb
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// filePins defers the deletion of the files the iterators may still open, the SST files and the value log segments
+// of the state they read, until the last iterator pinning them is closed.
+type filePins struct {
+	mu      sync.Mutex
+	refs    map[string]int      // Number of iterators pinning each file, by path
+	removed map[string]struct{} // Pinned files deleted meanwhile
+}
+
+// newFilePins returns an empty set of pinned files.
+func newFilePins() *filePins {
+	return &filePins{refs: make(map[string]int), removed: make(map[string]struct{})}
+}
+
+// pin keeps the files from being deleted until they are unpinned.
+func (p *filePins) pin(paths []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, path := range paths {
+		p.refs[path]++
+	}
+}
+
+// unpin releases the files, and deletes the ones removed meanwhile that are no longer pinned.
+func (p *filePins) unpin(paths []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	for _, path := range paths {
+		if p.refs[path]--; p.refs[path] > 0 {
+			continue
+		}
+		delete(p.refs, path)
+		if _, ok := p.removed[path]; ok {
+			delete(p.removed, path)
+			// The database may have been reopened meanwhile, deleting the files it found obsolete.
+			if e := os.Remove(path); e != nil && !errors.Is(e, os.ErrNotExist) && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// remove deletes the file, or defers it while the file is pinned.
+func (p *filePins) remove(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.refs[path] > 0 {
+		p.removed[path] = struct{}{}
+		return nil
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,208 @@
+package zendb
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Constants for the skiplist memtable.
+const (
+	skipListMaxHeight = 12   // Enough for millions of versions with a branching of 4
+	skipListBranching = 4    // One node in 4 rises to the next level
+	arenaMinChunk     = 16   // Nodes of the first chunk of an arena
+	arenaMaxChunk     = 4096 // Nodes of the largest chunks, chunks double in size up to it
+)
+
+// Memory taken by a node of the skiplist and by each level of its tower, besides the bytes of its key and value.
+const (
+	skipNodeSize  = int(unsafe.Sizeof(skipNode{}))
+	skipLevelSize = int(unsafe.Sizeof(atomic.Pointer[skipNode]{}))
+)
+
+// skipNode is a version of a key in the skiplist. Its pair is set before the node is linked, and never changes.
+type skipNode struct {
+	elem  Pair
+	tower []atomic.Pointer[skipNode] // Next node at each level the node rises to
+}
+
+// skipArena allocates the nodes of a skiplist and their towers in chunks, so that inserting a version costs
+// no allocation most of the time. A chunk is never moved, so the nodes keep their addresses.
+type skipArena struct {
+	nodes  []skipNode
+	towers []atomic.Pointer[skipNode]
+}
+
+// newNode allocates a node holding the pair, with a tower of the given height.
+func (a *skipArena) newNode(p Pair, height int) *skipNode {
+	if len(a.nodes) == cap(a.nodes) {
+		a.nodes = make([]skipNode, 0, min(max(2*cap(a.nodes), arenaMinChunk), arenaMaxChunk))
+	}
+	if cap(a.towers)-len(a.towers) < height {
+		a.towers = make([]atomic.Pointer[skipNode], 0, max(cap(a.nodes), skipListMaxHeight))
+	}
+	a.nodes = a.nodes[:len(a.nodes)+1]
+	n := &a.nodes[len(a.nodes)-1]
+	n.elem = p
+	end := len(a.towers) + height
+	n.tower = a.towers[len(a.towers):end:end]
+	a.towers = a.towers[:end]
+	return n
+}
+
+// skipList is a sorted table that takes one writer alongside any number of readers, without locking: a new node is
+// linked level by level from the bottom with atomic stores, so a reader sees it either not at all or fully
+// initialized. Writers must be serialized by the caller.
+type skipList struct {
+	head   *skipNode
+	height atomic.Int32 // Number of levels in use
+	count  atomic.Int64 // Number of nodes
+	arena  skipArena
+}
+
+// newSkipList returns an empty skiplist.
+func newSkipList() *skipList {
+	s := &skipList{head: &skipNode{tower: make([]atomic.Pointer[skipNode], skipListMaxHeight)}}
+	s.height.Store(1)
+	return s
+}
+
+// before checks if the node sorts before the version of the key: by key, then from the newest to the oldest.
+// A node of the same key and sequence number does not, so that a version inserted again comes first.
+func (n *skipNode) before(key string, seq uint64) bool {
+	return n.elem.key < key || (n.elem.key == key && n.elem.seq > seq)
+}
+
+// seek returns the first node that does not sort before the version of the key, and fills prev, when given,
+// with the last node before it at each level.
+func (s *skipList) seek(key string, seq uint64, prev []*skipNode) *skipNode {
+	x := s.head
+	for level := int(s.height.Load()) - 1; ; level-- {
+		next := x.tower[level].Load()
+		for next != nil && next.before(key, seq) {
+			x, next = next, next.tower[level].Load()
+		}
+		if prev != nil {
+			prev[level] = x
+		}
+		if level == 0 {
+			return next
+		}
+	}
+}
+
+// randomHeight returns the height of a new node.
+func randomHeight() int {
+	height := 1
+	for height < skipListMaxHeight && rand.Intn(skipListBranching) == 0 {
+		height++
+	}
+	return height
+}
+
+// put inserts a version. A version with the same key and sequence number is shadowed rather than replaced,
+// since readers may hold it, so every insertion adds the memory of a whole node.
+func (s *skipList) put(p Pair) int {
+	var prev [skipListMaxHeight]*skipNode
+	s.seek(p.key, p.seq, prev[:])
+	height := randomHeight()
+	if h := int(s.height.Load()); height > h {
+		for level := h; level < height; level++ {
+			prev[level] = s.head
+		}
+		// Readers seeing the new height before the node is linked find nil at the new levels, and go down.
+		s.height.Store(int32(height))
+	}
+	n := s.arena.newNode(p, height)
+	for level := 0; level < height; level++ {
+		n.tower[level].Store(prev[level].tower[level].Load())
+		prev[level].tower[level].Store(n)
+	}
+	s.count.Add(1)
+	return skipNodeSize + height*skipLevelSize + len(p.key) + len(p.value)
+}
+
+// find returns the newest version of the key with a sequence number lower or equal to seq.
+func (s *skipList) find(key string, seq uint64) (Pair, bool) {
+	n := s.seek(key, seq, nil)
+	if n == nil || n.elem.key != key {
+		return Pair{}, false
+	}
+	return n.elem, true
+}
+
+// Traverse returns the versions in order, without the shadowed ones.
+func (s *skipList) Traverse() []Pair {
+	pairs := make([]Pair, 0, s.count.Load())
+	for n := s.head.tower[0].Load(); n != nil; n = n.tower[0].Load() {
+		if last := len(pairs) - 1; last >= 0 && pairs[last].key == n.elem.key && pairs[last].seq == n.elem.seq {
+			continue
+		}
+		pairs = append(pairs, n.elem)
+	}
+	return pairs
+}
+
+// Len returns the number of versions, the shadowed ones included.
+func (s *skipList) Len() int {
+	return int(s.count.Load())
+}
+
+// concurrent reports that the skiplist is read while it is written.
+func (s *skipList) concurrent() bool {
+	return true
+}
+
+// iterator walks the skiplist in place, without the shadowed versions.
+func (s *skipList) iterator() internalIterator {
+	return &skipListIterator{list: s}
+}
+
+// skipListIterator walks the nodes of a skiplist, stopping at the first node of each version.
+type skipListIterator struct {
+	list *skipList
+	node *skipNode
+}
+
+func (it *skipListIterator) seek(key string) { it.node = it.list.seek(key, math.MaxUint64, nil) }
+func (it *skipListIterator) first()          { it.node = it.list.head.tower[0].Load() }
+func (it *skipListIterator) valid() bool     { return it.node != nil }
+func (it *skipListIterator) pair() Pair      { return it.node.elem }
+func (it *skipListIterator) error() error    { return nil }
+
+// next skips the versions shadowed by the current one.
+func (it *skipListIterator) next() {
+	n := it.node.tower[0].Load()
+	for n != nil && n.elem.key == it.node.elem.key && n.elem.seq == it.node.elem.seq {
+		n = n.tower[0].Load()
+	}
+	it.node = n
+}
+
+// prev seeks the last node before the current one, then the first node of its version.
+func (it *skipListIterator) prev() {
+	var prev [skipListMaxHeight]*skipNode
+	it.list.seek(it.node.elem.key, it.node.elem.seq, prev[:])
+	it.at(prev[0])
+}
+
+// last walks down the levels to the last node, then seeks the first node of its version.
+func (it *skipListIterator) last() {
+	x := it.list.head
+	for level := int(it.list.height.Load()) - 1; level >= 0; level-- {
+		for next := x.tower[level].Load(); next != nil; next = x.tower[level].Load() {
+			x = next
+		}
+	}
+	it.at(x)
+}
+
+// at positions at the first node of the version of n, or nowhere when n is the head.
+func (it *skipListIterator) at(n *skipNode) {
+	if n == it.list.head {
+		it.node = nil
+		return
+	}
+	it.node = it.list.seek(n.elem.key, n.elem.seq, nil)
+}
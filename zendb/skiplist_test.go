@@ -0,0 +1,208 @@
+package zendb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestSortedTables tests that both memtable types order the versions the same way and find the same ones.
+func TestSortedTables(t *testing.T) {
+	for _, typ := range []MemTableType{RedBlackTreeMemTable, SkipListMemTable} {
+		mem := newMemTable(typ)
+		for i := 0; i < 1000; i++ {
+			mem.put(Pair{marker: true, key: fmt.Sprintf("key%03d", i%100), value: fmt.Sprintf("value%d", i), seq: uint64(i + 1)})
+		}
+		mem.put(Pair{key: "key050", seq: 1001})
+		// A version written again with the same sequence number takes the place of the first one.
+		mem.put(Pair{marker: true, key: "key051", value: "again", seq: 952})
+
+		pairs := mem.table.Traverse()
+		if len(pairs) != 1001 {
+			t.Errorf("Type %d: expected 1001 versions, got %d", typ, len(pairs))
+		}
+		for i := 1; i < len(pairs); i++ {
+			if !less(pairs[i-1], pairs[i]) {
+				t.Errorf("Type %d: versions %+v and %+v out of order", typ, pairs[i-1], pairs[i])
+				break
+			}
+		}
+		if v, err := mem.Get("key007"); err != nil || v != "value907" {
+			t.Errorf("Type %d: expected value907, got %s (%v)", typ, v, err)
+		}
+		if p, err := mem.getAt("key007", 500); err != nil || p.value != "value407" {
+			t.Errorf("Type %d: expected value407 as of 500, got %s (%v)", typ, p.value, err)
+		}
+		if _, err := mem.Get("key050"); !errors.Is(err, ErrKeyDeleted) {
+			t.Errorf("Type %d: expected key050 to be deleted, got %v", typ, err)
+		}
+		if v, err := mem.Get("key051"); err != nil || v != "again" {
+			t.Errorf("Type %d: expected the version written again, got %s (%v)", typ, v, err)
+		}
+		if _, err := mem.getAt("key001", 1); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Type %d: expected no version of key001 as of 1, got %v", typ, err)
+		}
+
+		// The iterator walks the same versions in place, both ways.
+		it := mem.table.iterator()
+		var walked []Pair
+		for it.first(); it.valid(); it.next() {
+			walked = append(walked, it.pair())
+		}
+		if !reflect.DeepEqual(walked, pairs) {
+			t.Errorf("Type %d: expected the iterator to walk the %d versions, got %d", typ, len(pairs), len(walked))
+		}
+		n := 0
+		for it.last(); it.valid(); it.prev() {
+			if n++; it.pair() != pairs[len(pairs)-n] {
+				t.Errorf("Type %d: expected %+v walking back, got %+v", typ, pairs[len(pairs)-n], it.pair())
+				break
+			}
+		}
+		if n != len(pairs) {
+			t.Errorf("Type %d: expected %d versions walking back, got %d", typ, len(pairs), n)
+		}
+		if it.seek("key051"); !it.valid() || it.pair().value != "again" {
+			t.Errorf("Type %d: expected to seek the version written again", typ)
+		}
+		if it.prev(); !it.valid() || it.pair().key != "key050" {
+			t.Errorf("Type %d: expected key050 before key051", typ)
+		}
+	}
+}
+
+// TestSkipListConcurrentReads tests that readers use the skiplist while a writer inserts into it.
+func TestSkipListConcurrentReads(t *testing.T) {
+	s := newSkipList()
+	const n = 5000
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				// A version found is complete, and the versions seen are in order.
+				if p, ok := s.find(fmt.Sprintf("key%05d", i), math.MaxUint64); ok && p.value != fmt.Sprintf("value%d", i) {
+					t.Errorf("Expected value%d, got %s", i, p.value)
+				}
+				if i%500 == 0 {
+					pairs := s.Traverse()
+					for j := 1; j < len(pairs); j++ {
+						if pairs[j-1].key >= pairs[j].key {
+							t.Errorf("Keys %s and %s out of order", pairs[j-1].key, pairs[j].key)
+						}
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		s.put(Pair{marker: true, key: fmt.Sprintf("key%05d", i), value: fmt.Sprintf("value%d", i), seq: uint64(i + 1)})
+	}
+	wg.Wait()
+	if s.Len() != n {
+		t.Errorf("Expected %d versions, got %d", n, s.Len())
+	}
+}
+
+// TestSkipListMemTable tests a database using skiplist memtables through flushes, reads during writes and reopening.
+func TestSkipListMemTable(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{FlushThreshold: 300, MemTableType: SkipListMemTable}
+	lstm, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if v, err := lstm.Get([]byte("key000")); err == nil && string(v) != "value0" {
+				t.Errorf("Expected value0, got %s", v)
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := lstm.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Errorf("Error setting key-value pair: %v", err)
+		}
+	}
+	wg.Wait()
+	lstm.Del([]byte("key001"))
+	lstm.Close()
+
+	if lstm, err = Open(dir, opts); err != nil {
+		t.Fatalf("Error reopening Lstm: %v", err)
+	}
+	defer lstm.Close()
+	for i := 0; i < 100; i++ {
+		v, err := lstm.Get([]byte(fmt.Sprintf("key%03d", i)))
+		if i == 1 {
+			if err == nil {
+				t.Errorf("Expected key001 to be deleted, got %s", v)
+			}
+		} else if err != nil || string(v) != fmt.Sprintf("value%d", i) {
+			t.Errorf("Expected value%d, got %s (%v)", i, v, err)
+		}
+	}
+}
+
+// TestSkipListBatchReads tests that reads without a snapshot see the batches written to a skiplist memtable as a
+// whole: a batch sets a, then filler keys, then b to the same value, so a reader finding a at a value finds b at it
+// too, and a batch inserted but not yet published is not seen at all.
+func TestSkipListBatchReads(t *testing.T) {
+	lstm, err := Open(t.TempDir(), &Options{FlushThreshold: 1 << 20, MemTableType: SkipListMemTable})
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				a, errA := lstm.Get([]byte("a"))
+				b, errB := lstm.Get([]byte("b"))
+				if errA == nil && (errB != nil || string(b) < string(a)) {
+					t.Errorf("Expected b to be at least %s, got %s (%v)", a, b, errB)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 500; i++ {
+		b := NewWriteBatch()
+		value := []byte(fmt.Sprintf("%05d", i))
+		b.Set([]byte("a"), value)
+		for j := 0; j < 20; j++ {
+			b.Set([]byte(fmt.Sprintf("filler%02d", j)), value)
+		}
+		b.Set([]byte("b"), value)
+		if err := lstm.Write(b); err != nil {
+			t.Fatalf("Error writing batch: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// The first operation of a batch is in the memtable, the others are not yet.
+	lstm.writeMu.Lock()
+	defer lstm.writeMu.Unlock()
+	if err := lstm.apply([]Pair{{marker: true, key: "a", value: "torn", seq: lstm.LastSequence() + 1}}); err != nil {
+		t.Fatalf("Error applying the operation: %v", err)
+	}
+	if v, version, err := lstm.GetWithVersion([]byte("a")); err != nil || string(v) != "00499" || version != lstm.LastSequence()-21 {
+		t.Errorf("Expected the published value of a, got %s at version %d (%v)", v, version, err)
+	}
+}
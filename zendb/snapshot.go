@@ -0,0 +1,83 @@
+package zendb
+
+import "sort"
+
+// Snapshot is a point-in-time view of the database: reads through it see the writes up to its sequence number.
+// Compaction keeps the versions a snapshot needs until it is released.
+type Snapshot struct {
+	lstm *Lstm
+	seq  uint64
+}
+
+// ReadOptions controls a read.
+type ReadOptions struct {
+	Snapshot *Snapshot // Reads see the database as of the snapshot, or its latest state when nil
+}
+
+// sequence returns the sequence number the read sees: the one of its snapshot, or else latest, the last one published.
+func (ro *ReadOptions) sequence(latest uint64) uint64 {
+	if ro == nil || ro.Snapshot == nil {
+		return latest
+	}
+	return ro.Snapshot.seq
+}
+
+// NewSnapshot returns a snapshot of the current state of the database. It must be released after use.
+func (lstm *Lstm) NewSnapshot() *Snapshot {
+	lstm.mu.Lock()
+	defer lstm.mu.Unlock()
+	s := &Snapshot{lstm: lstm, seq: lstm.seq}
+	lstm.snapshots[s] = struct{}{}
+	return s
+}
+
+// Sequence returns the sequence number of the last write seen by the snapshot.
+func (s *Snapshot) Sequence() uint64 {
+	return s.seq
+}
+
+// Release releases the snapshot, letting compaction drop the versions only it needed.
+func (s *Snapshot) Release() {
+	s.lstm.mu.Lock()
+	defer s.lstm.mu.Unlock()
+	delete(s.lstm.snapshots, s)
+}
+
+// snapshotSequences returns the sorted sequence numbers of the live snapshots. The lock must be held.
+func (lstm *Lstm) snapshotSequences() []uint64 {
+	var seqs []uint64
+	for s := range lstm.snapshots {
+		seqs = append(seqs, s.seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
+
+// collapseVersions drops the versions no read can see from sorted pairs. A version is kept if it is the newest
+// version of its key, or if a snapshot sees it: the snapshot's sequence number is at least the version's,
+// and lower than the next newer version's. The deletions of the keys for which dropDelete returns true are
+// dropped too, as long as no older version of the key is kept.
+func collapseVersions(kv []Pair, snapshots []uint64, dropDelete func(key string) bool) []Pair {
+	var out []Pair
+	for i := 0; i < len(kv); {
+		j := i + 1
+		for j < len(kv) && kv[j].key == kv[i].key {
+			j++
+		}
+		start := len(out)
+		out = append(out, kv[i])
+		for k := i + 1; k < j; k++ {
+			n := sort.Search(len(snapshots), func(n int) bool { return snapshots[n] >= kv[k].seq })
+			if n < len(snapshots) && snapshots[n] < kv[k-1].seq {
+				out = append(out, kv[k])
+			}
+		}
+		if dropDelete != nil && dropDelete(kv[i].key) {
+			for len(out) > start && !out[len(out)-1].marker {
+				out = out[:len(out)-1]
+			}
+		}
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package zendb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestSnapshot tests that reads through a snapshot ignore later writes, through flushes and compactions.
+func TestSnapshot(t *testing.T) {
+	opts := &Options{FlushThreshold: 200, CompactionThreshold: 2, BaseLevelSize: 1 << 10, TargetFileSize: 256}
+	lstm, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+
+	for i := 0; i < 100; i++ {
+		lstm.Set([]byte(fmt.Sprintf("key%03d", i)), []byte("old"))
+	}
+	snap := lstm.NewSnapshot()
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			lstm.Set([]byte(fmt.Sprintf("key%03d", i)), []byte("new"))
+		} else {
+			lstm.Del([]byte(fmt.Sprintf("key%03d", i)))
+		}
+	}
+	lstm.Set([]byte("key100"), []byte("new"))
+	for {
+		compacted, err := lstm.compact()
+		if err != nil {
+			t.Fatalf("Error compacting: %v", err)
+		}
+		if !compacted {
+			break
+		}
+	}
+
+	ro := &ReadOptions{Snapshot: snap}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		if v, err := lstm.GetWithOptions([]byte(key), ro); err != nil || string(v) != "old" {
+			t.Errorf("Expected old for %s through the snapshot, got %s (%v)", key, v, err)
+		}
+		v, err := lstm.Get([]byte(key))
+		if i%2 == 0 && (err != nil || string(v) != "new") {
+			t.Errorf("Expected new for %s, got %s (%v)", key, v, err)
+		} else if i%2 == 1 && !errors.Is(err, ErrKeyDeleted) {
+			t.Errorf("Expected %s to be deleted, got %s (%v)", key, v, err)
+		}
+	}
+	if _, err := lstm.GetWithOptions([]byte("key100"), ro); err == nil {
+		t.Errorf("Expected key100 not to be seen through the snapshot")
+	}
+
+	it, err := lstm.NewIterator(&IteratorOptions{ReadOptions: *ro})
+	if err != nil {
+		t.Fatalf("Error creating iterator: %v", err)
+	}
+	count := 0
+	for it.Last(); it.Valid(); it.Prev() {
+		if string(it.Value()) != "old" {
+			t.Errorf("Expected old for %s through the snapshot iterator, got %s", it.Key(), it.Value())
+		}
+		count++
+	}
+	it.Close()
+	if count != 100 {
+		t.Errorf("Expected 100 keys through the snapshot iterator, got %d", count)
+	}
+	snap.Release()
+}
+
+// TestCollapseVersions tests that only the versions seen by a read are kept.
+func TestCollapseVersions(t *testing.T) {
+	kv := []Pair{
+		{marker: true, key: "a", seq: 9},
+		{marker: true, key: "a", seq: 7},
+		{marker: true, key: "a", seq: 4},
+		{marker: true, key: "a", seq: 2},
+		{marker: false, key: "b", seq: 8},
+		{marker: true, key: "b", seq: 3},
+		{marker: false, key: "c", seq: 6},
+		{marker: false, key: "c", seq: 5},
+	}
+	// The snapshot at 5 sees a@4 and b@3, the one at 8 sees a@7 and b@8.
+	out := collapseVersions(kv, []uint64{5, 8}, func(string) bool { return true })
+	var got []string
+	for _, p := range out {
+		got = append(got, fmt.Sprintf("%s@%d", p.key, p.seq))
+	}
+	if fmt.Sprint(got) != "[a@9 a@7 a@4 b@8 b@3]" {
+		t.Errorf("Unexpected versions kept: %v", got)
+	}
+	if out = collapseVersions(kv, nil, nil); len(out) != 3 {
+		t.Errorf("Expected only the newest versions without snapshots, got %d", len(out))
+	}
+}
@@ -0,0 +1,410 @@
+package zendb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Constants for file-related operations.
+const (
+	MAGIC                  = "zeni"
+	FileReadOnlyPermission = 0444
+	FilePermission         = 0666
+	FileFlags              = os.O_APPEND | os.O_CREATE | os.O_RDWR
+)
+
+// Constants for the SST format versions.
+const (
+	VersionV1     = 1                          // Entries follow the header, then a SHA-256 of the keys and values
+	VersionBlocks = 2                          // Checksummed data blocks, located by an index block and a footer, and a filter block
+	sstVersion    = VersionBlocks              // Version written by encodeSST
+	HeaderSize    = 4 + 4 + BloomLength + 2    // Magic, entry count, bloom filter and version
+	FooterSize    = 8 + 4 + 8 + 4 + len(MAGIC) // Index block and filter block offsets and sizes, then the magic
+	ChecksumSize  = 4                          // CRC32C following a checksummed block
+)
+
+// CorruptionError reports a checksum mismatch, locating the damaged part of the file.
+type CorruptionError struct {
+	File   string // Name of the file, when known
+	Offset int64  // Offset of the damaged part
+	Part   string // Damaged part: header, index block or data block
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("%s: %s at offset %d: %v", e.File, e.Part, e.Offset, ErrCorruptFile)
+}
+
+// Unwrap makes a CorruptionError match ErrCorruptFile.
+func (e *CorruptionError) Unwrap() error {
+	return ErrCorruptFile
+}
+
+// appendString appends a string preceded by its length as a varint, as the block-based format encodes it.
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// decodeBytes decodes a string from a ReadWriteSeeker.
+func decodeBytes(file io.ReadWriteSeeker) (string, error) {
+	var length uint16
+	err := binary.Read(file, binary.LittleEndian, &length)
+	if err != nil {
+		return "", ErrFileNotEncodedProperly
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(file, data)
+	if err != nil {
+		return "", ErrFileNotEncodedProperly
+	}
+	return string(data), nil
+}
+
+// decodeHeader decodes the header information from a file. A version other than the first and the block-based ones
+// is not recognized.
+func decodeHeader(file io.ReadSeeker) (string, uint32, *LegacyBloomFilter, uint16, error) {
+	file.Seek(0, io.SeekStart)
+
+	// Read magic bytes
+	p2 := make([]byte, 2)
+	p4 := make([]byte, 4)
+	if n, err := file.Read(p4); n != 4 || err != nil {
+		return "", 0, nil, 0, ErrFileNotEncodedProperly
+	}
+	magic := string(p4)
+
+	// Read entry count
+	if n, err := file.Read(p4); n != 4 || err != nil {
+		return "", 0, nil, 0, ErrFileNotEncodedProperly
+	}
+	entryCount := binary.LittleEndian.Uint32(p4)
+
+	// Read Bloom filter bitset
+	bitset := make([]byte, 29)
+	if n, err := file.Read(bitset); n != 29 || err != nil {
+		return "", 0, nil, 0, ErrFileNotEncodedProperly
+	}
+
+	// Read Version
+	file.Read(p2)
+	version := binary.LittleEndian.Uint16(p2)
+	if version != VersionV1 && version != VersionBlocks {
+		return "", 0, nil, 0, ErrFileNotRecognized
+	}
+	return magic, entryCount, CreateBloomFilter(bitset), version, nil
+}
+
+// parseBody parses the body of a file, updating the provided MemTable.
+func parseBody(file io.ReadWriteSeeker, entrycount int, mem *MemTable) error {
+	mark := make([]byte, 1)
+	var key, value string
+	var err error
+	h := sha256.New()
+	for i := 0; i < entrycount; i++ {
+		if _, err = file.Read(mark); err != nil {
+			return ErrFileNotEncodedProperly
+		}
+		key, err = decodeBytes(file)
+		if err != nil {
+			return err
+		}
+		if mark[0] == 's' {
+			value, err = decodeBytes(file)
+			if err != nil {
+				return err
+			}
+			mem.put(Pair{marker: true, key: key, value: value})
+			h.Write([]byte(key + value))
+		} else if mark[0] == 'd' {
+			mem.put(Pair{marker: false, key: key})
+			h.Write([]byte(key))
+		} else {
+			return ErrFileNotEncodedProperly
+		}
+
+	}
+
+	// Read and compare the hash value
+	p := make([]byte, 32)
+	if n, err := file.Read(p); n < 32 || err != nil {
+		return ErrFileNotEncodedProperly
+	}
+	if bytes.Compare(h.Sum(nil), p) != 0 {
+		return ErrCorruptFile
+	}
+	return nil
+}
+
+// Search searches for a key in the file and returns its value.
+func Search(key string, file io.ReadWriteSeeker) (string, error) {
+	p, err := searchAt(key, math.MaxUint64, file)
+	return p.value, err
+}
+
+// searchAt searches for the newest version of a key in the file with a sequence number lower or equal to seq.
+func searchAt(key string, seq uint64, file io.ReadWriteSeeker) (Pair, error) {
+	magic, entryCount, bloom, version, err := decodeHeader(file)
+	if err != nil {
+		return Pair{}, err
+	}
+	if magic != MAGIC {
+		return Pair{}, ErrFileNotRecognized
+	}
+	if version == VersionBlocks {
+		return searchBlocks(key, seq, blockFile{file})
+	}
+	if !bloom.Test([]byte(key)) {
+		return Pair{}, ErrKeyCannotBeInFile
+	}
+	mem := NewMemTable()
+	err = parseBody(file, int(entryCount), mem)
+	if err != nil {
+		return Pair{}, err
+	}
+	return mem.getAt(key, seq)
+}
+
+// Parse parses the file and updates the provided MemTable.
+func Parse(file io.ReadWriteSeeker, mem *MemTable) error {
+	magic, entryCount, _, version, err := decodeHeader(file)
+	if err != nil {
+		return err
+	}
+	if magic != MAGIC {
+		return ErrFileNotRecognized
+	}
+	if version == VersionBlocks {
+		return parseBlocks(blockFile{file}, mem)
+	}
+	return parseBody(file, int(entryCount), mem)
+}
+
+// blockHandle locates a data block and the range of keys it holds.
+type blockHandle struct {
+	firstKey string
+	lastKey  string
+	offset   uint64
+	size     uint32
+}
+
+// encode encodes the handle as an entry of the index block.
+func (h blockHandle) encode() []byte {
+	buf := appendString(appendString(nil, h.firstKey), h.lastKey)
+	buf = binary.LittleEndian.AppendUint64(buf, h.offset)
+	return binary.LittleEndian.AppendUint32(buf, h.size)
+}
+
+// blockFile reads the blocks of a file written with the block-based version of the SST format.
+type blockFile struct {
+	file io.ReadSeeker
+}
+
+// verifyHeader verifies the checksum of the header.
+func (b blockFile) verifyHeader() error {
+	_, err := b.readBlock(0, HeaderSize, "header")
+	return err
+}
+
+// footer locates the index block and the filter block.
+type footer struct {
+	indexOffset  uint64
+	indexSize    uint32
+	filterOffset uint64
+	filterSize   uint32
+}
+
+// encode encodes the footer.
+func (f footer) encode() []byte {
+	buf := binary.LittleEndian.AppendUint64(nil, f.indexOffset)
+	buf = binary.LittleEndian.AppendUint32(buf, f.indexSize)
+	buf = binary.LittleEndian.AppendUint64(buf, f.filterOffset)
+	buf = binary.LittleEndian.AppendUint32(buf, f.filterSize)
+	return append(buf, MAGIC...)
+}
+
+// readFooter reads the footer at the end of the file.
+func (b blockFile) readFooter() (footer, error) {
+	size := FooterSize
+	if _, err := b.file.Seek(-int64(size), io.SeekEnd); err != nil {
+		return footer{}, ErrFileNotEncodedProperly
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(b.file, data); err != nil {
+		return footer{}, ErrFileNotEncodedProperly
+	}
+	if string(data[size-len(MAGIC):]) != MAGIC {
+		return footer{}, ErrFileNotRecognized
+	}
+	r := &byteReader{data: data}
+	return footer{indexOffset: r.uint64(), indexSize: r.uint32(), filterOffset: r.uint64(), filterSize: r.uint32()}, nil
+}
+
+// readIndex reads the index block located by the footer.
+func (b blockFile) readIndex(f footer) ([]blockHandle, error) {
+	data, err := b.readBlock(f.indexOffset, f.indexSize, "index block")
+	if err != nil {
+		return nil, err
+	}
+
+	var index []blockHandle
+	r := &byteReader{data: data}
+	for !r.done() {
+		h := blockHandle{firstKey: r.entryString(), lastKey: r.entryString(), offset: r.uint64(), size: r.uint32()}
+		index = append(index, h)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return index, nil
+}
+
+// readFilter reads the bloom filter from the filter block.
+func (b blockFile) readFilter(f footer) (*BloomFilter, error) {
+	data, err := b.readBlock(f.filterOffset, f.filterSize, "filter block")
+	if err != nil {
+		return nil, err
+	}
+	return DecodeBloomFilter(data)
+}
+
+// readBlock reads size bytes starting at offset, verifying the checksum following them.
+func (b blockFile) readBlock(offset uint64, size uint32, part string) ([]byte, error) {
+	if _, err := b.file.Seek(int64(offset), io.SeekStart); err != nil {
+		return nil, ErrFileNotEncodedProperly
+	}
+	data := make([]byte, int(size)+ChecksumSize)
+	if _, err := io.ReadFull(b.file, data); err != nil {
+		return nil, ErrFileNotEncodedProperly
+	}
+	if crc32.Checksum(data[:size], crcTable) != binary.LittleEndian.Uint32(data[size:]) {
+		return nil, &CorruptionError{File: fileName(b.file), Offset: int64(offset), Part: part}
+	}
+	return data[:size], nil
+}
+
+// fileName returns the name of the file, or an empty string for readers without one.
+func fileName(file io.Reader) string {
+	if f, ok := file.(interface{ Name() string }); ok {
+		return f.Name()
+	}
+	return ""
+}
+
+// decodeBlock decodes the entries of a data block, stopping early when fn returns false.
+func decodeBlock(block []byte, fn func(p Pair) bool) error {
+	r := &byteReader{data: block}
+	for !r.done() {
+		var p Pair
+		mark := r.byte()
+		if mark == 'S' || mark == 'T' {
+			mark += 'a' - 'A'
+			p.indirect = true
+		}
+		p.seq = r.uint64()
+		if mark == 't' {
+			mark = 's'
+			p.expires = int64(r.uint64())
+		}
+		p.key = r.entryString()
+		switch mark {
+		case 's':
+			p.marker = true
+			p.value = r.entryString()
+		case 'd':
+		default:
+			return ErrFileNotEncodedProperly
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if !fn(p) {
+			return nil
+		}
+	}
+	return r.err
+}
+
+// searchBlocks searches for the newest version of a key with a sequence number lower or equal to seq in
+// a block-based SST file, reading its filter and index first.
+func searchBlocks(key string, seq uint64, b blockFile) (Pair, error) {
+	t, err := readTable(b)
+	if err != nil {
+		return Pair{}, err
+	}
+	return t.search(key, seq, b.file)
+}
+
+// search binary searches the index for the first data block that may hold the key, then reads the blocks
+// of the file holding its versions until it finds the newest one with a sequence number lower or equal to seq.
+func (t *sstTable) search(key string, seq uint64, file io.ReadSeeker) (Pair, error) {
+	if !t.bloom.Test([]byte(key)) {
+		return Pair{}, ErrKeyCannotBeInFile
+	}
+	b, index := blockFile{file}, t.index
+	// The versions of the key may continue over the next blocks.
+	var found *Pair
+	done := false
+	for i := sort.Search(len(index), func(i int) bool { return index[i].lastKey >= key }); !done && i < len(index) && index[i].firstKey <= key; i++ {
+		block, err := b.readBlock(index[i].offset, index[i].size, "data block")
+		if err != nil {
+			return Pair{}, err
+		}
+		err = decodeBlock(block, func(p Pair) bool {
+			if p.key > key || (p.key == key && p.seq <= seq) {
+				if p.key == key {
+					found = &p
+				}
+				done = true
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return Pair{}, err
+		}
+	}
+	if found == nil {
+		return Pair{}, ErrKeyNotFound
+	}
+	if !found.marker {
+		return *found, ErrKeyDeleted
+	}
+	return *found, nil
+}
+
+// parseBlocks parses every data block, updating the provided MemTable.
+func parseBlocks(b blockFile, mem *MemTable) error {
+	if err := b.verifyHeader(); err != nil {
+		return err
+	}
+	f, err := b.readFooter()
+	if err != nil {
+		return err
+	}
+	index, err := b.readIndex(f)
+	if err != nil {
+		return err
+	}
+	for _, h := range index {
+		block, err := b.readBlock(h.offset, h.size, "data block")
+		if err != nil {
+			return err
+		}
+		err = decodeBlock(block, func(p Pair) bool {
+			mem.put(p)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
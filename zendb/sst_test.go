@@ -0,0 +1,228 @@
+package zendb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var pairs = map[string]string{
+	"status":    "active",
+	"priority":  "high",
+	"key1":      "value1",
+	"injustice": "destroy",
+	"soul":      "elamari",
+	"zakaria":   "elmaachi",
+}
+
+// TestDecodeBytes tests the decodeBytes function.
+func TestDecodeBytes(t *testing.T) {
+	t.Helper()
+	testFile, err := os.Open("test_file.sst")
+	if err != nil {
+		t.Fatalf("Error opening test file: %v", err)
+	}
+	defer testFile.Close()
+
+	testFile.Seek(40, io.SeekStart)
+	result, err := decodeBytes(testFile)
+	if err != nil {
+		t.Errorf("Error decoding bytes: %v", err)
+	}
+	if result != "injustice" {
+		t.Errorf("DecodeBytes is not well implemented: %v", result)
+	}
+
+	result, err = decodeBytes(testFile)
+	if err != nil {
+		t.Errorf("Error decoding bytes: %v", err)
+	}
+	if result != "destroy" {
+		t.Errorf("DecodeBytes is not well implemented: %v", result)
+	}
+}
+
+// TestDecodeHeader tests the decodeHeader function.
+func TestDecodeHeader(t *testing.T) {
+	t.Helper()
+	testFile, err := os.Open("test_file.sst")
+	if err != nil {
+		t.Fatalf("Error opening test file: %v", err)
+	}
+	defer testFile.Close()
+
+	magic, entryCount, _, version, err := decodeHeader(testFile)
+	if err != nil {
+		t.Errorf("Error decoding header: %v", err)
+	}
+
+	if magic != MAGIC {
+		t.Errorf("Error decoding header, Magic Number: %v", magic)
+	}
+	if entryCount != 6 {
+		t.Errorf("Error decoding header, EntryCount: %v", entryCount)
+	}
+	if version != 1 {
+		t.Errorf("Error decoding header, Version Number: %v", version)
+	}
+}
+
+// TestParse tests the parse function.
+func TestParse(t *testing.T) {
+	t.Helper()
+	testFile, err := os.Open("test_file.sst")
+	if err != nil {
+		t.Fatalf("Error opening test file: %v", err)
+	}
+	defer testFile.Close()
+	testFile.Seek(39, io.SeekStart)
+	mem := NewMemTable()
+	err = parseBody(testFile, 6, mem)
+	if err != nil {
+		t.Errorf("Error parsing file: %v", err)
+	}
+
+	if mem.table.Len() != 6 {
+		t.Error("Error parsing file - Table Size")
+	}
+	for k, v := range pairs {
+		value, err := mem.Get(k)
+		if err != nil {
+			t.Errorf("Error parsing file: %v", err)
+		}
+		if value != v {
+			t.Errorf("Error parsing file - value conflict")
+		}
+	}
+}
+
+// TestSearch tests the search function.
+func TestSearch(t *testing.T) {
+	t.Helper()
+	testFile, err := os.Open("test_file.sst")
+	if err != nil {
+		t.Fatalf("Error opening test file: %v", err)
+	}
+	defer testFile.Close()
+
+	for k, v := range pairs {
+		value, err := Search(k, testFile)
+		if err != nil {
+			t.Errorf("Error searching for key: %v", k)
+		}
+		if value != v {
+			t.Errorf("Error getting value: %v", value)
+		}
+	}
+}
+
+// TestBlockFormat tests that a block-based SST file is searched and parsed block by block.
+func TestBlockFormat(t *testing.T) {
+	var kv []Pair
+	for i := 0; i < 200; i++ {
+		kv = append(kv, Pair{marker: i%10 != 0, key: fmt.Sprintf("key%03d", i), value: fmt.Sprintf("value%d", i)})
+	}
+	fileName := filepath.Join(t.TempDir(), "blocks.sst")
+	if err := writeSST(fileName, kv, &Options{BlockSize: 128, BloomBitsPerKey: DefaultBloomBitsPerKey}); err != nil {
+		t.Fatalf("Error writing SST file: %v", err)
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("Error opening SST file: %v", err)
+	}
+	defer file.Close()
+
+	_, entryCount, _, version, err := decodeHeader(file)
+	if err != nil || entryCount != 200 || version != sstVersion {
+		t.Errorf("Unexpected header: %d entries, version %d (%v)", entryCount, version, err)
+	}
+	b := blockFile{file}
+	f, err := b.readFooter()
+	if err != nil {
+		t.Fatalf("Error reading footer: %v", err)
+	}
+	index, err := b.readIndex(f)
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	if len(index) < 2 || index[0].firstKey != "key000" || index[len(index)-1].lastKey != "key199" {
+		t.Errorf("Unexpected index: %d blocks", len(index))
+	}
+
+	for i, p := range kv {
+		value, err := Search(p.key, file)
+		if i%10 == 0 {
+			if !errors.Is(err, ErrKeyDeleted) {
+				t.Errorf("Expected %s to be deleted, got %v", p.key, err)
+			}
+		} else if err != nil || value != p.value {
+			t.Errorf("Expected %s for %s, got %s (%v)", p.value, p.key, value, err)
+		}
+	}
+	if _, err := searchBlocks("key0505", math.MaxUint64, b); !errors.Is(err, ErrKeyNotFound) && !errors.Is(err, ErrKeyCannotBeInFile) {
+		t.Errorf("Expected a missing key, got %v", err)
+	}
+
+	mem := NewMemTable()
+	if err := Parse(file, mem); err != nil {
+		t.Fatalf("Error parsing SST file: %v", err)
+	}
+	if mem.table.Len() != 200 {
+		t.Errorf("Expected 200 parsed entries, got %d", mem.table.Len())
+	}
+}
+
+// TestBlockChecksums tests that a damaged block only fails the reads touching it, and is located.
+func TestBlockChecksums(t *testing.T) {
+	var kv []Pair
+	for i := 0; i < 100; i++ {
+		kv = append(kv, Pair{marker: true, key: fmt.Sprintf("key%03d", i), value: fmt.Sprintf("value%d", i)})
+	}
+	fileName := filepath.Join(t.TempDir(), "checksums.sst")
+	if err := writeSST(fileName, kv, &Options{BlockSize: 128, BloomBitsPerKey: DefaultBloomBitsPerKey}); err != nil {
+		t.Fatalf("Error writing SST file: %v", err)
+	}
+	file, err := os.OpenFile(fileName, os.O_RDWR, FilePermission)
+	if err != nil {
+		t.Fatalf("Error opening SST file: %v", err)
+	}
+	defer file.Close()
+	b := blockFile{file}
+	f, err := b.readFooter()
+	if err != nil {
+		t.Fatalf("Error reading footer: %v", err)
+	}
+	index, err := b.readIndex(f)
+	if err != nil || len(index) < 3 {
+		t.Fatalf("Expected at least 3 blocks, got %d (%v)", len(index), err)
+	}
+
+	// Damage the last byte of the second data block.
+	damaged := index[1]
+	file.WriteAt([]byte{0xff}, int64(damaged.offset)+int64(damaged.size)-1)
+
+	if v, err := Search(index[0].firstKey, file); err != nil || v == "" {
+		t.Errorf("Expected the first block to stay readable, got %s (%v)", v, err)
+	}
+	_, err = Search(damaged.firstKey, file)
+	var corruption *CorruptionError
+	if !errors.As(err, &corruption) || !errors.Is(err, ErrCorruptFile) {
+		t.Fatalf("Expected a corruption error, got %v", err)
+	}
+	if corruption.File != fileName || corruption.Offset != int64(damaged.offset) || corruption.Part != "data block" {
+		t.Errorf("Corruption not located: %v", corruption)
+	}
+	if err := Parse(file, NewMemTable()); !errors.Is(err, ErrCorruptFile) {
+		t.Errorf("Expected parsing the whole file to fail, got %v", err)
+	}
+
+	// Damage the entry count in the header.
+	file.WriteAt([]byte{0xff}, 5)
+	if _, err := Search(index[0].firstKey, file); !errors.As(err, &corruption) || corruption.Part != "header" {
+		t.Errorf("Expected a header corruption error, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package zendb
+
+import "time"
+
+// makeRoomForWrite delays or stops a write while the flush or the compaction falls behind, so that a burst of writes
+// does not pile up frozen memtables and level 0 files. Past the slowdown triggers, the write is delayed once by
+// SlowdownDelay; past the stop triggers, it waits until a flush or a compaction brings the backlog under them.
+// The lock must be held, and is released while waiting. It returns ErrClosed if the database is closed meanwhile,
+// and ErrWriteStall if the write waited longer than WriteStallTimeout.
+func (lstm *Lstm) makeRoomForWrite() error {
+	delayed := false
+	var deadline <-chan time.Time // Never fires without a timeout
+	for {
+		if lstm.closed {
+			return ErrClosed
+		}
+		frozen, files := len(lstm.imm), lstm.picker.backlog(lstm)
+		switch {
+		case frozen >= lstm.opts.ImmutableStopTrigger || files >= lstm.opts.L0StopTrigger:
+			if deadline == nil && lstm.opts.WriteStallTimeout > 0 {
+				timer := time.NewTimer(lstm.opts.WriteStallTimeout)
+				defer timer.Stop()
+				deadline = timer.C
+			}
+			// A flush or a compaction that failed is retried now rather than after its next delay.
+			lstm.triggerFlush()
+			lstm.TriggerCompaction()
+			wake := lstm.writeC
+			lstm.mu.Unlock()
+			select {
+			case <-wake:
+			case <-deadline:
+				lstm.mu.Lock()
+				return ErrWriteStall
+			}
+			lstm.mu.Lock()
+		case !delayed && (frozen >= lstm.opts.ImmutableSlowdownTrigger || files >= lstm.opts.L0SlowdownTrigger):
+			lstm.mu.Unlock()
+			time.Sleep(lstm.opts.SlowdownDelay)
+			lstm.mu.Lock()
+			delayed = true
+		default:
+			return nil
+		}
+	}
+}
+
+// wakeWriters wakes the writes waiting for the backlog to shrink, after a flush, a compaction or Close.
+// The lock must be held.
+func (lstm *Lstm) wakeWriters() {
+	close(lstm.writeC)
+	lstm.writeC = make(chan struct{})
+}
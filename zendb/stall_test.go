@@ -0,0 +1,125 @@
+package zendb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWriteStall tests that writes stop while the frozen memtables are at their stop trigger, fail once they
+// waited past the timeout, and resume when the flush catches up.
+func TestWriteStall(t *testing.T) {
+	lstm, err := Open(t.TempDir(), &Options{
+		FlushThreshold:           50,
+		ImmutableSlowdownTrigger: 1,
+		ImmutableStopTrigger:     1,
+		WriteStallTimeout:        50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+
+	// Holding the flush lock keeps the frozen memtable in memory.
+	lstm.flushMu.Lock()
+	for i := 0; i < 10; i++ {
+		if err := lstm.Set([]byte(fmt.Sprintf("key%d", i)), []byte("a value to fill the memtable")); err != nil {
+			break
+		}
+	}
+	start := time.Now()
+	if err := lstm.Set([]byte("stalled"), []byte("value")); !errors.Is(err, ErrWriteStall) {
+		t.Errorf("Expected a write stall, got %v", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("Expected the write to wait for the timeout, it waited %v", waited)
+	}
+	if _, err := lstm.Get([]byte("key0")); err != nil {
+		t.Errorf("Expected reads to go on during a stall, got %v", err)
+	}
+
+	lstm.mu.Lock()
+	lstm.opts.WriteStallTimeout = 0
+	lstm.mu.Unlock()
+	done := make(chan error, 1)
+	go func() { done <- lstm.Set([]byte("waiting"), []byte("value")) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the write to wait for the flush, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	lstm.flushMu.Unlock()
+	lstm.triggerFlush()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Error setting key-value pair after the flush: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the flush to resume the write")
+	}
+	if v, err := lstm.Get([]byte("waiting")); err != nil || string(v) != "value" {
+		t.Errorf("Expected the resumed write to be applied, got %s (%v)", v, err)
+	}
+}
+
+// TestWriteStallRetry tests that a write stalled behind a failed flush resumes once the flush succeeds again, with
+// nothing but the retry of the flush waking it.
+func TestWriteStallRetry(t *testing.T) {
+	dir := t.TempDir()
+	sstDir := filepath.Join(dir, "sst")
+	lstm, err := Open(dir, &Options{
+		SSTDir:                   sstDir,
+		FlushThreshold:           50,
+		ImmutableSlowdownTrigger: 1,
+		ImmutableStopTrigger:     1,
+	})
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+
+	// A file in place of the SST directory fails the flushes.
+	if err := os.Rename(sstDir, sstDir+".moved"); err != nil {
+		t.Fatalf("Error moving the SST directory: %v", err)
+	}
+	if err := os.WriteFile(sstDir, nil, 0o644); err != nil {
+		t.Fatalf("Error creating a file in place of the SST directory: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 10; i++ {
+			if err := lstm.Set([]byte(fmt.Sprintf("key%d", i)), []byte("a value to fill the memtable")); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the writes to stall behind the failed flush, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := os.Remove(sstDir); err != nil {
+		t.Fatalf("Error removing the file: %v", err)
+	}
+	if err := os.Rename(sstDir+".moved", sstDir); err != nil {
+		t.Fatalf("Error restoring the SST directory: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Error setting key-value pair after the flush: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the retried flush to resume the writes")
+	}
+	if v, err := lstm.Get([]byte("key9")); err != nil || string(v) != "a value to fill the memtable" {
+		t.Errorf("Expected the resumed writes to be applied, got %s (%v)", v, err)
+	}
+}
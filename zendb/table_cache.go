@@ -0,0 +1,77 @@
+package zendb
+
+import (
+	"io"
+	"sync"
+)
+
+// sstTable holds the parts of a block-based SST file that every lookup needs: its bloom filter and its index.
+type sstTable struct {
+	bloom *BloomFilter
+	index []blockHandle
+}
+
+// readTable reads and verifies the header, the footer, the bloom filter and the index of a block-based SST file.
+func readTable(b blockFile) (*sstTable, error) {
+	if err := b.verifyHeader(); err != nil {
+		return nil, err
+	}
+	f, err := b.readFooter()
+	if err != nil {
+		return nil, err
+	}
+	bloom, err := b.readFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	index, err := b.readIndex(f)
+	if err != nil {
+		return nil, err
+	}
+	return &sstTable{bloom: bloom, index: index}, nil
+}
+
+// tableCache keeps the bloom filter and the index of the live SST files once read, so that a lookup only reads
+// the data blocks that may hold its key. A table is dropped when its file is removed.
+type tableCache struct {
+	mu     sync.Mutex
+	tables map[int]*sstTable // Tables by file number
+}
+
+// newTableCache returns an empty table cache.
+func newTableCache() *tableCache {
+	return &tableCache{tables: make(map[int]*sstTable)}
+}
+
+// get returns the table of the file with the given number, reading it from the file the first time. It returns nil
+// for the files of the first version, which have no index to keep.
+func (c *tableCache) get(num int, file io.ReadSeeker) (*sstTable, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.tables[num]; ok {
+		return t, nil
+	}
+	magic, _, _, version, err := decodeHeader(file)
+	if err != nil {
+		return nil, err
+	}
+	if magic != MAGIC {
+		return nil, ErrFileNotRecognized
+	}
+	if version == VersionV1 {
+		return nil, nil
+	}
+	t, err := readTable(blockFile{file})
+	if err != nil {
+		return nil, err
+	}
+	c.tables[num] = t
+	return t, nil
+}
+
+// drop forgets the table of the file with the given number.
+func (c *tableCache) drop(num int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tables, num)
+}
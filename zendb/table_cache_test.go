@@ -0,0 +1,69 @@
+package zendb
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestTableCache tests that lookups keep the filters and indexes of the files they read, and that a compaction
+// drops the ones of the files it removes.
+func TestTableCache(t *testing.T) {
+	lstm, err := Open(t.TempDir(), &Options{FlushThreshold: 1000, CompactionThreshold: 100})
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+	for i := 0; i < 50; i++ {
+		lstm.Set([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+	waitForFlush(t, lstm)
+
+	live := func() map[int]bool {
+		lstm.mu.RLock()
+		defer lstm.mu.RUnlock()
+		nums := make(map[int]bool)
+		for _, files := range lstm.levels {
+			for _, f := range files {
+				nums[f.num] = true
+			}
+		}
+		return nums
+	}
+	check := func() {
+		for i := 0; i < 50; i++ {
+			if v, err := lstm.Get([]byte(fmt.Sprintf("key%02d", i))); err != nil || string(v) != fmt.Sprintf("value%d", i) {
+				t.Errorf("Expected value%d, got %s (%v)", i, v, err)
+			}
+		}
+		nums := live()
+		lstm.tables.mu.Lock()
+		defer lstm.tables.mu.Unlock()
+		if len(lstm.tables.tables) == 0 {
+			t.Errorf("Expected the lookups to cache the tables")
+		}
+		for num := range lstm.tables.tables {
+			if !nums[num] {
+				t.Errorf("Expected the table of the removed file %d to be dropped", num)
+			}
+		}
+	}
+	check()
+	before := live()
+
+	lstm.mu.Lock()
+	lstm.opts.CompactionThreshold = 2
+	lstm.mu.Unlock()
+	for {
+		compacted, err := lstm.compact()
+		if err != nil {
+			t.Fatalf("Error compacting: %v", err)
+		}
+		if !compacted {
+			break
+		}
+	}
+	if after := live(); len(after) >= len(before) {
+		t.Fatalf("Expected the compaction to merge the %d files, got %d", len(before), len(after))
+	}
+	check()
+}
@@ -0,0 +1,124 @@
+package zendb
+
+import "math"
+
+// Txn is an optimistic transaction. Its reads see the database as of Begin, and its writes are buffered
+// until Commit, which applies them atomically unless a key the transaction read was written in the meantime.
+type Txn struct {
+	lstm   *Lstm
+	snap   *Snapshot
+	reads  map[string]uint64 // Version of each key read, its deletion included, 0 when it was never written
+	writes map[string]Pair   // Last write of each key, to read the transaction's own writes
+	batch  *WriteBatch
+	done   bool
+}
+
+// Begin starts a transaction.
+func (lstm *Lstm) Begin() (*Txn, error) {
+	lstm.mu.RLock()
+	closed := lstm.closed
+	lstm.mu.RUnlock()
+	if closed {
+		return nil, ErrClosed
+	}
+	return &Txn{
+		lstm:   lstm,
+		snap:   lstm.NewSnapshot(),
+		reads:  make(map[string]uint64),
+		writes: make(map[string]Pair),
+		batch:  NewWriteBatch(),
+	}, nil
+}
+
+// Get retrieves the value associated with a key, as of the start of the transaction or as written by it.
+func (txn *Txn) Get(key []byte) ([]byte, error) {
+	if txn.done {
+		return nil, ErrTxnDone
+	}
+	if p, ok := txn.writes[string(key)]; ok {
+		if !p.marker {
+			return nil, ErrKeyDeleted
+		}
+		return []byte(p.value), nil
+	}
+	txn.lstm.mu.RLock()
+	defer txn.lstm.mu.RUnlock()
+	if txn.lstm.closed {
+		return nil, ErrClosed
+	}
+	p, err := txn.lstm.searchAt(string(key), txn.snap.seq)
+	if _, ok := txn.reads[string(key)]; !ok {
+		txn.reads[string(key)] = versionOf(p, err)
+	}
+	return valueOf(p, err), err
+}
+
+// Expect records that the transaction read the version of the key, as returned by GetWithVersion.
+// Commit fails if the key was written since.
+func (txn *Txn) Expect(key []byte, version uint64) {
+	txn.reads[string(key)] = version
+}
+
+// Set sets a key when the transaction commits.
+func (txn *Txn) Set(key, value []byte) error {
+	if txn.done {
+		return ErrTxnDone
+	}
+	txn.batch.Set(key, value)
+	txn.writes[string(key)] = Pair{marker: true, key: string(key), value: string(value)}
+	return nil
+}
+
+// Del deletes a key when the transaction commits.
+func (txn *Txn) Del(key []byte) error {
+	if txn.done {
+		return ErrTxnDone
+	}
+	txn.batch.Del(key)
+	txn.writes[string(key)] = Pair{marker: false, key: string(key)}
+	return nil
+}
+
+// Commit applies the writes of the transaction atomically. It returns ErrConflict, and applies nothing,
+// when a key the transaction read was written since, be it set, deleted, or set and deleted again.
+func (txn *Txn) Commit() error {
+	if txn.done {
+		return ErrTxnDone
+	}
+	txn.done = true
+	lstm := txn.lstm
+	defer txn.snap.Release()
+	// The newest version of a key, a deletion included, only differs from the one read if the key was written since.
+	check := func(seq uint64) error {
+		for key, version := range txn.reads {
+			if p, _ := lstm.findAt(key, seq); p.seq != version {
+				return ErrConflict
+			}
+		}
+		return nil
+	}
+	// A read-only transaction does not wait for the backlog.
+	if txn.batch.Len() == 0 {
+		lstm.mu.RLock()
+		defer lstm.mu.RUnlock()
+		if lstm.closed {
+			return ErrClosed
+		}
+		return check(lstm.seq)
+	}
+	return lstm.update(func() ([]Pair, error) {
+		// No write is being applied while writeMu is held, so every version is published.
+		if err := check(math.MaxUint64); err != nil {
+			return nil, err
+		}
+		return append([]Pair(nil), txn.batch.ops...), nil
+	})
+}
+
+// Rollback discards the transaction.
+func (txn *Txn) Rollback() {
+	if !txn.done {
+		txn.done = true
+		txn.snap.Release()
+	}
+}
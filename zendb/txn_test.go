@@ -0,0 +1,117 @@
+package zendb
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestTxnConflict tests that a transaction fails to commit when a key it read was written since it started.
+func TestTxnConflict(t *testing.T) {
+	lstm, err := Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+	lstm.Set([]byte("balance"), []byte("10"))
+
+	txn, err := lstm.Begin()
+	if err != nil {
+		t.Fatalf("Error beginning transaction: %v", err)
+	}
+	if v, err := txn.Get([]byte("balance")); err != nil || string(v) != "10" {
+		t.Fatalf("Expected 10, got %s (%v)", v, err)
+	}
+	lstm.Set([]byte("balance"), []byte("20"))
+	// The transaction keeps reading the state it started with, and its own writes.
+	if v, _ := txn.Get([]byte("balance")); string(v) != "10" {
+		t.Errorf("Expected the transaction to read 10, got %s", v)
+	}
+	txn.Set([]byte("history"), []byte("withdrawal"))
+	if v, err := txn.Get([]byte("history")); err != nil || string(v) != "withdrawal" {
+		t.Errorf("Expected the transaction to read its own write, got %s (%v)", v, err)
+	}
+	if err := txn.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected a conflict, got %v", err)
+	}
+	if _, err := lstm.Get([]byte("history")); err == nil {
+		t.Errorf("Expected the writes of the failed transaction to be discarded")
+	}
+	if err := txn.Set([]byte("history"), []byte("again")); !errors.Is(err, ErrTxnDone) {
+		t.Errorf("Expected the transaction to be done, got %v", err)
+	}
+
+	txn, _ = lstm.Begin()
+	txn.Get([]byte("balance"))
+	txn.Set([]byte("balance"), []byte("15"))
+	txn.Del([]byte("history"))
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Error committing: %v", err)
+	}
+	if v, _ := lstm.Get([]byte("balance")); string(v) != "15" {
+		t.Errorf("Expected 15 after the commit, got %s", v)
+	}
+	if len(lstm.snapshots) != 0 {
+		t.Errorf("Expected the transactions to release their snapshots")
+	}
+}
+
+// TestTxnExpect tests that a transaction checks the versions it was given.
+func TestTxnExpect(t *testing.T) {
+	lstm, err := Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	defer lstm.Close()
+	lstm.Set([]byte("key"), []byte("value"))
+	_, version, err := lstm.GetWithVersion([]byte("key"))
+	if err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %d (%v)", version, err)
+	}
+
+	txn, _ := lstm.Begin()
+	txn.Expect([]byte("key"), version)
+	txn.Expect([]byte("missing"), 0)
+	txn.Set([]byte("key"), []byte("new"))
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Error committing: %v", err)
+	}
+
+	txn, _ = lstm.Begin()
+	txn.Expect([]byte("key"), version)
+	txn.Set([]byte("key"), []byte("newer"))
+	if err := txn.Commit(); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a conflict on a stale version, got %v", err)
+	}
+	txn.Rollback()
+
+	// A deleted key has the version of its deletion.
+	lstm.Del([]byte("key"))
+	_, deleted, err := lstm.GetWithVersion([]byte("key"))
+	if !errors.Is(err, ErrKeyDeleted) || deleted <= version {
+		t.Errorf("Expected the version of the deletion, got %d (%v)", deleted, err)
+	}
+	txn, _ = lstm.Begin()
+	txn.Expect([]byte("key"), version)
+	txn.Set([]byte("key"), []byte("again"))
+	if err := txn.Commit(); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a conflict on a key deleted since, got %v", err)
+	}
+	txn, _ = lstm.Begin()
+	txn.Expect([]byte("key"), deleted)
+	txn.Set([]byte("key"), []byte("again"))
+	if err := txn.Commit(); err != nil {
+		t.Errorf("Error committing over a deleted key: %v", err)
+	}
+
+	// A key set and deleted again since it was read as absent was written meanwhile.
+	txn, _ = lstm.Begin()
+	if _, err := txn.Get([]byte("gone")); !errors.Is(err, ErrKeyNotFound) && !errors.Is(err, ErrKeyDeleted) {
+		t.Errorf("Expected gone to be absent, got %v", err)
+	}
+	lstm.Set([]byte("gone"), []byte("value"))
+	lstm.Del([]byte("gone"))
+	txn.Set([]byte("other"), []byte("value"))
+	if err := txn.Commit(); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a conflict on a key set and deleted since, got %v", err)
+	}
+}
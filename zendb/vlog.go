@@ -0,0 +1,472 @@
+package zendb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Constants for the value log files.
+const (
+	vlogSuffix       = ".vlog"
+	valuePointerSize = 4 + 8 + 4 // Segment, offset and size of an entry
+)
+
+// vlogNameRegex matches the names of the value log segments.
+var vlogNameRegex = regexp.MustCompile(`^(\d+)\.vlog$`)
+
+// valuePointer locates an entry of the value log.
+type valuePointer struct {
+	segment uint32
+	offset  uint64
+	size    uint32
+}
+
+// encode encodes the pointer, stored in place of the value in the LSM tree.
+func (vp valuePointer) encode() string {
+	b := binary.LittleEndian.AppendUint32(nil, vp.segment)
+	b = binary.LittleEndian.AppendUint64(b, vp.offset)
+	return string(binary.LittleEndian.AppendUint32(b, vp.size))
+}
+
+// decodeValuePointer decodes a pointer encoded by encode.
+func decodeValuePointer(s string) (valuePointer, error) {
+	if len(s) != valuePointerSize {
+		return valuePointer{}, ErrFileNotEncodedProperly
+	}
+	b := []byte(s)
+	return valuePointer{
+		segment: binary.LittleEndian.Uint32(b),
+		offset:  binary.LittleEndian.Uint64(b[4:]),
+		size:    binary.LittleEndian.Uint32(b[12:]),
+	}, nil
+}
+
+// valueLog holds the values moved out of the SST files, so that compaction rewrites small pointers instead of
+// large values. It is a sequence of append-only segments, and only the last one, the head, is appended to.
+// Every open starts a new head, so a segment torn by a crash is never appended to. The flush appends to it without
+// holding the lock of the LSM tree, so the segments are guarded by their own mutex.
+type valueLog struct {
+	mu       sync.Mutex
+	dir      string
+	fileSize int64    // Size in bytes at which the head is sealed and a new one started
+	segments []uint32 // Numbers of the segments, from the oldest to the head
+	last     uint32   // Number of the newest segment, not given again after its deletion
+	pins     *filePins
+	head     *os.File // Nil until the first value is appended
+	headSize int64
+}
+
+// openValueLog lists the segments of the value log in dir. The directory is created with the first segment.
+// The segments pinned by the iterators are deleted once unpinned.
+func openValueLog(dir string, fileSize int64, pins *filePins) (*valueLog, error) {
+	vl := &valueLog{dir: dir, fileSize: fileSize, pins: pins}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if m := vlogNameRegex.FindStringSubmatch(entry.Name()); m != nil {
+			num, err := strconv.ParseUint(m[1], 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			vl.segments = append(vl.segments, uint32(num))
+		}
+	}
+	sort.Slice(vl.segments, func(i, j int) bool { return vl.segments[i] < vl.segments[j] })
+	if len(vl.segments) > 0 {
+		vl.last = vl.segments[len(vl.segments)-1]
+	}
+	return vl, nil
+}
+
+// segmentPath returns the path of a segment.
+func (vl *valueLog) segmentPath(num uint32) string {
+	return filepath.Join(vl.dir, fmt.Sprintf("%06d%s", num, vlogSuffix))
+}
+
+// sealed returns the numbers of the segments that are no longer appended to.
+func (vl *valueLog) sealed() []uint32 {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	if vl.head == nil {
+		return append([]uint32(nil), vl.segments...)
+	}
+	return append([]uint32(nil), vl.segments[:len(vl.segments)-1]...)
+}
+
+// rotate seals the head, if any, and starts a new one.
+func (vl *valueLog) rotate() error {
+	if vl.head != nil {
+		if err := vl.head.Close(); err != nil {
+			return err
+		}
+		vl.head = nil
+	}
+	if err := os.MkdirAll(vl.dir, os.ModePerm); err != nil {
+		return err
+	}
+	num := vl.last + 1
+	file, err := os.OpenFile(vl.segmentPath(num), os.O_CREATE|os.O_EXCL|os.O_WRONLY, FilePermission)
+	if err != nil {
+		return err
+	}
+	vl.head, vl.headSize = file, 0
+	vl.segments = append(vl.segments, num)
+	vl.last = num
+	return nil
+}
+
+// append appends the keys and values of the pairs to the head and syncs it, then returns the pointers to them.
+// The head is sealed once it reaches the file size.
+func (vl *valueLog) append(kv []Pair) ([]valuePointer, error) {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	if vl.head == nil || vl.headSize >= vl.fileSize {
+		if err := vl.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	num := vl.segments[len(vl.segments)-1]
+	var data []byte
+	pointers := make([]valuePointer, len(kv))
+	for i, p := range kv {
+		entry := encodeValueEntry(p.key, p.value)
+		pointers[i] = valuePointer{segment: num, offset: uint64(vl.headSize) + uint64(len(data)), size: uint32(len(entry))}
+		data = append(data, entry...)
+	}
+	if _, err := vl.head.Write(data); err != nil {
+		return nil, ErrWriteFailed
+	}
+	vl.headSize += int64(len(data))
+	if err := vl.head.Sync(); err != nil {
+		return nil, ErrSyncFailed
+	}
+	return pointers, nil
+}
+
+// read reads the value of the key located by the pointer.
+func (vl *valueLog) read(key, pointer string) (string, error) {
+	vp, err := decodeValuePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(vl.segmentPath(vp.segment))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return readValueEntry(file, key, vp)
+}
+
+// remove deletes a sealed segment, once no iterator pins it.
+func (vl *valueLog) remove(num uint32) error {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	for i, n := range vl.segments {
+		if n == num {
+			vl.segments = append(vl.segments[:i], vl.segments[i+1:]...)
+			break
+		}
+	}
+	return vl.pins.remove(vl.segmentPath(num))
+}
+
+// has checks if the segment exists.
+func (vl *valueLog) has(num uint32) bool {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	for _, n := range vl.segments {
+		if n == num {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentPaths returns the paths of every segment, by segment number.
+func (vl *valueLog) segmentPaths() map[uint32]string {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	paths := make(map[uint32]string, len(vl.segments))
+	for _, num := range vl.segments {
+		paths[num] = vl.segmentPath(num)
+	}
+	return paths
+}
+
+// close closes the head.
+func (vl *valueLog) close() error {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+	if vl.head == nil {
+		return nil
+	}
+	return vl.head.Close()
+}
+
+// encodeValueEntry encodes a key and its value as an entry of the value log: their lengths as varints,
+// the key, the value, then the CRC32C of the entry.
+func encodeValueEntry(key, value string) []byte {
+	entry := binary.AppendUvarint(nil, uint64(len(key)))
+	entry = binary.AppendUvarint(entry, uint64(len(value)))
+	entry = append(append(entry, key...), value...)
+	return appendChecksum(entry)
+}
+
+// decodeValueEntry decodes the entry at the start of data, and returns its key, its value and its size.
+func decodeValueEntry(data []byte) (string, string, int, error) {
+	keyLen, n := binary.Uvarint(data)
+	if n <= 0 {
+		return "", "", 0, ErrFileNotEncodedProperly
+	}
+	valueLen, m := binary.Uvarint(data[n:])
+	if m <= 0 {
+		return "", "", 0, ErrFileNotEncodedProperly
+	}
+	rest := uint64(len(data) - n - m)
+	if keyLen > rest || valueLen > rest-keyLen || rest-keyLen-valueLen < ChecksumSize {
+		return "", "", 0, ErrFileNotEncodedProperly
+	}
+	size := n + m + int(keyLen) + int(valueLen)
+	if binary.LittleEndian.Uint32(data[size:]) != crc32.Checksum(data[:size], crcTable) {
+		return "", "", 0, ErrCorruptFile
+	}
+	key := string(data[n+m : n+m+int(keyLen)])
+	return key, string(data[n+m+int(keyLen) : size]), size + ChecksumSize, nil
+}
+
+// readValueEntry reads the value of the key from the entry located by the pointer.
+func readValueEntry(file io.ReaderAt, key string, vp valuePointer) (string, error) {
+	data := make([]byte, vp.size)
+	if _, err := file.ReadAt(data, int64(vp.offset)); err != nil {
+		return "", ErrReadFailed
+	}
+	k, value, size, err := decodeValueEntry(data)
+	if err != nil || k != key || size != len(data) {
+		return "", &CorruptionError{File: vlogFileName(file), Offset: int64(vp.offset), Part: "value log entry"}
+	}
+	return value, nil
+}
+
+// vlogFileName returns the name of the file, when it has one.
+func vlogFileName(file io.ReaderAt) string {
+	if f, ok := file.(*os.File); ok {
+		return f.Name()
+	}
+	return ""
+}
+
+// separateValues moves the values of at least ValueThreshold bytes to the value log, leaving pointers in their
+// place. Nothing is moved when the threshold is 0.
+func (lstm *Lstm) separateValues(kv []Pair) ([]Pair, error) {
+	if lstm.opts.ValueThreshold <= 0 {
+		return kv, nil
+	}
+	var large []int
+	var pairs []Pair
+	for i, p := range kv {
+		if p.marker && !p.indirect && len(p.value) >= lstm.opts.ValueThreshold {
+			large = append(large, i)
+			pairs = append(pairs, p)
+		}
+	}
+	if len(pairs) == 0 {
+		return kv, nil
+	}
+	pointers, err := lstm.vlog.append(pairs)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range large {
+		kv[i].value, kv[i].indirect = pointers[j].encode(), true
+	}
+	return kv, nil
+}
+
+// countPointers adds the sizes of the entries the pairs point to, times sign, to the bytes of their segments.
+// The segments whose bytes come back to 0 are left out.
+func countPointers(bytes map[uint32]int64, kv []Pair, sign int64) {
+	for _, p := range kv {
+		if !p.indirect {
+			continue
+		}
+		if vp, err := decodeValuePointer(p.value); err == nil {
+			if bytes[vp.segment] += sign * int64(vp.size); bytes[vp.segment] == 0 {
+				delete(bytes, vp.segment)
+			}
+		}
+	}
+}
+
+// GCValueLog rewrites the sealed segment of the value log with the largest share of bytes the LSM tree no longer
+// points to, if fewer than ValueLogGCRatio of its bytes are still pointed to: its live values are appended to the
+// head, the pointers are updated, and the segment is deleted. The bytes no longer pointed to are counted as the
+// compactions and the flushes drop the versions pointing to them, so only the picked segment is read. It returns
+// the number of segments deleted, at most one. It does nothing while a snapshot is live, since the snapshot may read
+// values the latest state no longer points to.
+func (lstm *Lstm) GCValueLog() (int, error) {
+	lstm.gcMu.Lock()
+	defer lstm.gcMu.Unlock()
+	return lstm.gcValueLog(func() bool {
+		lstm.writeMu.Lock()
+		return true
+	})
+}
+
+// gcValueLog runs the garbage collector of the value log. gcMu must be held. The segment is read and its live values
+// appended without writeMu, which lockWrites then takes, or fails to take, to point the keys to the moved values:
+// holding it, the values found still live stay so until then.
+func (lstm *Lstm) gcValueLog(lockWrites func() bool) (int, error) {
+	num, ok, err := lstm.pickSegment()
+	if err != nil || !ok {
+		return 0, err
+	}
+	live, old, liveSize, size, err := lstm.liveValues(num)
+	if err != nil {
+		return 0, err
+	}
+	if size > 0 && float64(liveSize) >= lstm.opts.ValueLogGCRatio*float64(size) {
+		return 0, nil
+	}
+	var moved []valuePointer
+	if len(live) > 0 {
+		if moved, err = lstm.vlog.append(live); err != nil {
+			return 0, err
+		}
+	}
+
+	// The moved values no key ends up pointing to are bytes of the head no longer pointed to.
+	e := &versionEdit{discards: make(map[uint32]int64)}
+	if !lockWrites() {
+		for _, vp := range moved {
+			e.discards[vp.segment] += int64(vp.size)
+		}
+		lstm.mu.Lock()
+		defer lstm.mu.Unlock()
+		return 0, lstm.logEdit(e)
+	}
+	var ops []Pair
+	lstm.mu.RLock()
+	closed := lstm.closed
+	for i, p := range live {
+		if q, err := lstm.findAt(p.key, math.MaxUint64); err == nil && q.indirect && q.value == old[i] {
+			p.value, p.indirect = moved[i].encode(), true
+			ops = append(ops, p)
+		} else {
+			e.discards[moved[i].segment] += int64(moved[i].size)
+		}
+	}
+	lstm.mu.RUnlock()
+	if closed {
+		lstm.writeMu.Unlock()
+		return 0, ErrClosed
+	}
+	// The new pointers are durable in the WAL before the segment is deleted. They keep the sequence numbers of
+	// the writes, so their versions do not change.
+	if len(ops) > 0 {
+		if err := lstm.apply(ops); err != nil {
+			lstm.writeMu.Unlock()
+			return 0, err
+		}
+	}
+	lstm.mu.Lock()
+	lstm.memFlush()
+	lstm.writeMu.Unlock()
+	defer lstm.mu.Unlock()
+
+	// A snapshot taken meanwhile may still read the values of the segment that were overwritten since it was read,
+	// so the segment is kept. The versions pointing to its moved values are shadowed by the new ones.
+	if len(lstm.snapshots) > 0 {
+		for _, p := range ops {
+			vp, _ := decodeValuePointer(p.value)
+			e.discards[num] += int64(vp.size)
+		}
+		return 0, lstm.logEdit(e)
+	}
+	// The reads hold the lock from finding a pointer to reading its value, so none reads the segment once deleted.
+	if err := lstm.vlog.remove(num); err != nil {
+		return 0, err
+	}
+	e.removedSegments = []uint32{num}
+	return 1, lstm.logEdit(e)
+}
+
+// pickSegment returns the sealed segment with the largest share of bytes no longer pointed to, and reports whether
+// that share is high enough to rewrite it. A flush appends values the LSM tree does not point to until its file is
+// installed, so the sealed segments are listed under flushMu: the ones sealed by the later flushes are not picked.
+func (lstm *Lstm) pickSegment() (uint32, bool, error) {
+	lstm.flushMu.Lock()
+	sealed := lstm.vlog.sealed()
+	lstm.flushMu.Unlock()
+	lstm.mu.RLock()
+	closed, snapshots := lstm.closed, len(lstm.snapshots)
+	discards := make([]int64, len(sealed))
+	for i, num := range sealed {
+		discards[i] = lstm.discards[num]
+	}
+	lstm.mu.RUnlock()
+	if closed {
+		return 0, false, ErrClosed
+	}
+	if snapshots > 0 {
+		return 0, false, nil
+	}
+	var picked uint32
+	var share float64
+	for i, num := range sealed {
+		if discards[i] == 0 {
+			continue
+		}
+		info, err := os.Stat(lstm.vlog.segmentPath(num))
+		if err != nil {
+			return 0, false, err
+		}
+		if s := float64(discards[i]) / float64(info.Size()); s > share {
+			picked, share = num, s
+		}
+	}
+	return picked, share > 1-lstm.opts.ValueLogGCRatio, nil
+}
+
+// liveValues reads the segment, and returns the versions of the LSM tree still pointing to it with their values,
+// the pointers to them, the bytes they take, and the size of the segment. The lock is taken for each entry, so the
+// writes go on meanwhile.
+func (lstm *Lstm) liveValues(num uint32) ([]Pair, []string, int, int, error) {
+	data, err := os.ReadFile(lstm.vlog.segmentPath(num))
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	var live []Pair
+	var pointers []string
+	liveSize, now := 0, time.Now().UnixNano()
+	for offset := 0; offset < len(data); {
+		key, value, size, err := decodeValueEntry(data[offset:])
+		if err != nil {
+			break // The tail of a segment torn by a crash holds no value the LSM tree points to.
+		}
+		pointer := valuePointer{segment: num, offset: uint64(offset), size: uint32(size)}.encode()
+		lstm.mu.RLock()
+		p, err := lstm.findAt(key, math.MaxUint64)
+		lstm.mu.RUnlock()
+		if err == nil && p.indirect && p.value == pointer && !p.expired(now) {
+			p.value, p.indirect = value, false
+			live = append(live, p)
+			pointers = append(pointers, pointer)
+			liveSize += size
+		}
+		offset += size
+	}
+	return live, pointers, liveSize, len(data), nil
+}
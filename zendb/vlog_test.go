@@ -0,0 +1,161 @@
+package zendb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestValueEntry tests the encoding of the value log entries and the detection of corrupted ones.
+func TestValueEntry(t *testing.T) {
+	entry := encodeValueEntry("key", "value")
+	key, value, size, err := decodeValueEntry(append(entry, "next"...))
+	if err != nil || key != "key" || value != "value" || size != len(entry) {
+		t.Errorf("Expected key and value of %d bytes, got %q, %q, %d (%v)", len(entry), key, value, size, err)
+	}
+	entry[3] ^= 0xff
+	if _, _, _, err := decodeValueEntry(entry); !errors.Is(err, ErrCorruptFile) {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+	if _, _, _, err := decodeValueEntry(entry[:5]); !errors.Is(err, ErrFileNotEncodedProperly) {
+		t.Errorf("Expected a truncated entry error, got %v", err)
+	}
+	vp := valuePointer{segment: 3, offset: 1 << 40, size: 17}
+	if got, err := decodeValuePointer(vp.encode()); err != nil || got != vp {
+		t.Errorf("Expected %+v, got %+v (%v)", vp, got, err)
+	}
+}
+
+// TestValueLog tests that large values move to the value log on flush and stay readable through compaction,
+// iterators and a reopen, while small values stay in the SST files.
+func TestValueLog(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{FlushThreshold: 500, CompactionThreshold: 2, ValueThreshold: 64}
+	lstm, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	large := func(i int) []byte { return bytes.Repeat([]byte{byte('a' + i%26)}, 100+i) }
+	for i := 0; i < 30; i++ {
+		if err := lstm.Set([]byte(fmt.Sprintf("large%02d", i)), large(i)); err != nil {
+			t.Errorf("Error setting key-value pair: %v", err)
+		}
+		if err := lstm.Set([]byte(fmt.Sprintf("small%02d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Errorf("Error setting key-value pair: %v", err)
+		}
+	}
+	waitForFlush(t, lstm)
+	if segments, _ := filepath.Glob(filepath.Join(dir, DefaultValueLogDir, "*.vlog")); len(segments) == 0 {
+		t.Errorf("Expected the flushed values in the value log")
+	}
+
+	check := func() {
+		for i := 0; i < 30; i++ {
+			if v, err := lstm.Get([]byte(fmt.Sprintf("large%02d", i))); err != nil || !bytes.Equal(v, large(i)) {
+				t.Errorf("Expected the large value %d, got %q (%v)", i, v, err)
+			}
+			if v, err := lstm.Get([]byte(fmt.Sprintf("small%02d", i))); err != nil || string(v) != fmt.Sprintf("value%d", i) {
+				t.Errorf("Expected value%d, got %q (%v)", i, v, err)
+			}
+		}
+		it, err := lstm.NewIterator(&IteratorOptions{UpperBound: []byte("small")})
+		if err != nil {
+			t.Fatalf("Error creating iterator: %v", err)
+		}
+		defer it.Close()
+		n := 0
+		for it.First(); it.Valid(); it.Next() {
+			if !bytes.Equal(it.Value(), large(n)) {
+				t.Errorf("Expected the large value %d from the iterator, got %q", n, it.Value())
+			}
+			n++
+		}
+		if err := it.Err(); err != nil || n != 30 {
+			t.Errorf("Expected 30 large values from the iterator, got %d (%v)", n, err)
+		}
+	}
+	check()
+	lstm.Close()
+
+	if lstm, err = Open(dir, opts); err != nil {
+		t.Fatalf("Error reopening Lstm: %v", err)
+	}
+	defer lstm.Close()
+	check()
+}
+
+// TestValueLogGC tests that the compactions count the bytes of the overwritten values, and that the garbage
+// collector then deletes the segment holding them, moves the live values it still holds, and keeps their versions.
+func TestValueLogGC(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{FlushThreshold: 300, CompactionThreshold: 2, ValueThreshold: 32, ValueLogFileSize: 256}
+	lstm, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Error creating Lstm: %v", err)
+	}
+	value := func(i, round int) []byte { return []byte(fmt.Sprintf("%064d", i*100+round)) }
+	for i := 0; i < 20; i++ {
+		lstm.Set([]byte(fmt.Sprintf("key%02d", i)), value(i, 0))
+	}
+	_, kept, _ := lstm.GetWithVersion([]byte("key00"))
+	// Every key but the first is overwritten, so the first segment is mostly garbage.
+	for i := 1; i < 20; i++ {
+		lstm.Set([]byte(fmt.Sprintf("key%02d", i)), value(i, 1))
+	}
+	waitForFlush(t, lstm)
+	// The compactions drop the overwritten versions, and wake the collector in the background, which may delete
+	// the segment first.
+	first := filepath.Join(dir, DefaultValueLogDir, "000001.vlog")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := lstm.GCValueLog(); err != nil {
+			t.Fatalf("Error collecting the value log: %v", err)
+		}
+		if _, err := os.Stat(first); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the first segment to be deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	lstm.mu.RLock()
+	_, counted := lstm.discards[1]
+	lstm.mu.RUnlock()
+	if counted {
+		t.Errorf("Expected the bytes of the deleted segment to be forgotten")
+	}
+
+	check := func() {
+		if v, version, err := lstm.GetWithVersion([]byte("key00")); err != nil || !bytes.Equal(v, value(0, 0)) || version != kept {
+			t.Errorf("Expected the moved value at version %d, got %q at %d (%v)", kept, v, version, err)
+		}
+		for i := 1; i < 20; i++ {
+			if v, err := lstm.Get([]byte(fmt.Sprintf("key%02d", i))); err != nil || !bytes.Equal(v, value(i, 1)) {
+				t.Errorf("Expected the overwritten value %d, got %q (%v)", i, v, err)
+			}
+		}
+	}
+	check()
+	lstm.mu.RLock()
+	discards := make(map[uint32]int64)
+	for num, n := range lstm.discards {
+		discards[num] = n
+	}
+	lstm.mu.RUnlock()
+	lstm.Close()
+
+	if lstm, err = Open(dir, opts); err != nil {
+		t.Fatalf("Error reopening Lstm: %v", err)
+	}
+	defer lstm.Close()
+	check()
+	if !reflect.DeepEqual(lstm.discards, discards) {
+		t.Errorf("Expected the discarded bytes %v after reopening, got %v", discards, lstm.discards)
+	}
+}
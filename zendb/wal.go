@@ -0,0 +1,274 @@
+package zendb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// BufferSize represents the size of the buffer used for reading and writing.
+	BufferSize = 2048
+)
+
+var (
+	// ErrWriteFailed is an error when writing to the WAL fails.
+	ErrWriteFailed = errors.New("write to WAL failed")
+	// ErrSyncFailed is an error when syncing the WAL file fails.
+	ErrSyncFailed = errors.New("syncing WAL file failed")
+	// ErrReadFailed is an error when reading from the WAL file fails.
+	ErrReadFailed = errors.New("read from WAL file failed")
+)
+
+// Marks of the WAL records.
+const (
+	walSet    = 's' // Set, without sequence number
+	walDel    = 'd' // Deletion, without sequence number
+	walRecord = 'r' // Batch of operations framed by its length and checksummed
+)
+
+// walRecordHeader is the size of the header of a framed record: its mark, CRC32C and length.
+const walRecordHeader = 9
+
+// WALRecoveryMode selects how the recovery of the WAL handles a torn or corrupt record.
+type WALRecoveryMode int
+
+const (
+	// TolerateCorruptTailRecovery drops a torn or corrupt record at the end of the WAL, which a crash may have left
+	// while it was written, and fails if a valid record follows it.
+	TolerateCorruptTailRecovery WALRecoveryMode = iota
+	// SkipCorruptRecordsRecovery drops every torn or corrupt record, and recovers the valid records following them.
+	SkipCorruptRecordsRecovery
+	// AbsoluteConsistencyRecovery fails on any torn or corrupt record, at the end of the WAL as well.
+	AbsoluteConsistencyRecovery
+)
+
+// Wal represents the Write-Ahead Log.
+type Wal struct {
+	file *os.File
+}
+
+// Write appends the given operation to the WAL and flushes immediately.
+func (w *Wal) Write(op []byte) error {
+	if _, err := w.file.Write(op); err != nil {
+		return ErrWriteFailed
+	}
+	if err := w.file.Sync(); err != nil {
+		return ErrSyncFailed
+	}
+	return nil
+}
+
+// RecordBatch records operations in the WAL, as a single record. Every write is recorded as a batch.
+func (w *Wal) RecordBatch(ops []Pair) error {
+	return w.Write(encodeBatch(ops))
+}
+
+// encodeBatch encodes operations as a framed WAL record: the record mark, the CRC32C of the rest of the record and
+// the length of the payload on 4 bytes each, then the payload, the number of operations on 4 bytes followed by the
+// operations encoded as entries of the SST data blocks.
+func encodeBatch(ops []Pair) []byte {
+	payload := binary.LittleEndian.AppendUint32(nil, uint32(len(ops)))
+	for _, p := range ops {
+		payload = append(payload, encodeEntry(p)...)
+	}
+	body := binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))
+	body = append(body, payload...)
+	record := binary.LittleEndian.AppendUint32([]byte{walRecord}, crc32.Checksum(body, crcTable))
+	return append(record, body...)
+}
+
+// decodeRecord decodes the record at the start of data, and returns its operations and its size. Besides the framed
+// records, it reads the set and deletion records of the first format, which have no checksum. It returns io.ErrUnexpectedEOF
+// when the record is torn, and ErrCorruptFile when its checksum does not match.
+func decodeRecord(data []byte) ([]Pair, int, error) {
+	switch mark := data[0]; mark {
+	case walRecord:
+		if len(data) < walRecordHeader {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		length := binary.LittleEndian.Uint32(data[5:walRecordHeader])
+		if uint64(length) > uint64(len(data)-walRecordHeader) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		size := walRecordHeader + int(length)
+		if crc32.Checksum(data[5:size], crcTable) != binary.LittleEndian.Uint32(data[1:5]) {
+			return nil, 0, ErrCorruptFile
+		}
+		payload := data[walRecordHeader:size]
+		if len(payload) < 4 {
+			return nil, 0, ErrFileNotEncodedProperly
+		}
+		ops, err := decodeOps(payload[4:], binary.LittleEndian.Uint32(payload))
+		return ops, size, err
+	case walSet, walDel:
+		key, rest, err := cutLegacyString(data[1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		p := Pair{marker: mark == walSet, key: key}
+		if p.marker {
+			if p.value, rest, err = cutLegacyString(rest); err != nil {
+				return nil, 0, err
+			}
+		}
+		return []Pair{p}, len(data) - len(rest), nil
+	}
+	return nil, 0, ErrFileNotEncodedProperly
+}
+
+// cutLegacyString cuts a string with its length on 2 bytes from the start of data, as the first records encoded it.
+func cutLegacyString(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	length := int(binary.LittleEndian.Uint16(data)) + 2
+	if len(data) < length {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	return string(data[2:length]), data[length:], nil
+}
+
+// decodeOps decodes the given number of operations, encoded as entries of the SST data blocks.
+func decodeOps(data []byte, count uint32) ([]Pair, error) {
+	var ops []Pair
+	err := decodeBlock(data, func(p Pair) bool {
+		ops = append(ops, p)
+		return true
+	})
+	if err != nil || len(ops) != int(count) {
+		return nil, ErrFileNotEncodedProperly
+	}
+	return ops, nil
+}
+
+// resyncRecord returns the offset of the first valid framed record after the invalid record at offset, or -1 if there
+// is none. When the length in the header of a framed record is intact, the search resumes after the whole record,
+// so that a value holding an encoded record is not replayed as a write of its own. The length is trusted when the
+// record ends with the data, or where a valid record starts.
+func resyncRecord(data []byte, offset int) int {
+	if rec := data[offset:]; len(rec) >= walRecordHeader && rec[0] == walRecord {
+		length := uint64(binary.LittleEndian.Uint32(rec[5:walRecordHeader]))
+		switch end := uint64(offset+walRecordHeader) + length; {
+		case end == uint64(len(data)):
+			return -1
+		case end < uint64(len(data)) && data[end] == walRecord:
+			if _, _, err := decodeRecord(data[end:]); err == nil {
+				return int(end)
+			}
+		}
+	}
+	return nextRecord(data, offset+1)
+}
+
+// nextRecord returns the offset of the first valid framed record of data from the given offset on, or -1 if
+// there is none. The records of the first format are not looked for, having no checksum to tell them from garbage.
+func nextRecord(data []byte, from int) int {
+	for i := from; i < len(data); i++ {
+		if data[i] != walRecord {
+			continue
+		}
+		if _, _, err := decodeRecord(data[i:]); err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// seal closes the WAL and moves it to the sealed segment at path, then starts a new, empty WAL in its place.
+// The writes of a frozen memtable stay in its sealed segments until the memtable is flushed.
+func (w *Wal) seal(path string) error {
+	name := w.file.Name()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	// The file is closed before it is renamed, which Windows requires.
+	renameErr := os.Rename(name, path)
+	var err error
+	if w.file, err = os.OpenFile(name, FileFlags, FilePermission); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// walSegmentPath returns the path of a sealed segment of the WAL.
+func walSegmentPath(walPath string, num uint64) string {
+	return fmt.Sprintf("%s.%06d", walPath, num)
+}
+
+// walSegments returns the numbers of the sealed segments of the WAL, from the oldest to the newest.
+func walSegments(walPath string) ([]uint64, error) {
+	entries, err := os.ReadDir(filepath.Dir(walPath))
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(walPath) + "."
+	var nums []uint64
+	for _, entry := range entries {
+		if suffix, ok := strings.CutPrefix(entry.Name(), prefix); ok {
+			if num, err := strconv.ParseUint(suffix, 10, 64); err == nil {
+				nums = append(nums, num)
+			}
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	return nums, nil
+}
+
+// removeWALSegments deletes the sealed segments of the WAL up to the given number.
+func removeWALSegments(walPath string, upTo uint64) error {
+	nums, err := walSegments(walPath)
+	if err != nil {
+		return err
+	}
+	for _, num := range nums {
+		if num > upTo {
+			break
+		}
+		if err := os.Remove(walSegmentPath(walPath, num)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clean removes watermarked entries from the WAL while updating the watermark, in an atomic way
+func (w *Wal) Clean() error {
+	name := w.file.Name()
+
+	// Close the current file.
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	// Reopen the file in read-write mode and truncate it to clear its content.
+	var err error
+	w.file, err = os.OpenFile(name, os.O_RDWR, FilePermission)
+	if err != nil {
+		return err
+	}
+	err = w.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	// Close and reopen the file for appending.
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file, err = os.OpenFile(name, FileFlags, FilePermission)
+	if err != nil {
+		return err
+	}
+	return nil
+}
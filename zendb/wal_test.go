@@ -0,0 +1,191 @@
+package zendb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWalWrite tests the Write method of Wal.
+func TestWalWrite(t *testing.T) {
+	fileName := "test.wal"
+	wal := &Wal{
+		file: createTestFile(t, fileName),
+	}
+
+	defer func() {
+		if err := wal.file.Close(); err != nil {
+			t.Errorf("Error closing test file '%s': %v", fileName, err)
+		}
+		if err := os.Remove(fileName); err != nil {
+			t.Errorf("Error removing test file '%s': %v", fileName, err)
+		}
+	}()
+
+	op := []byte("test operation")
+
+	err := wal.Write(op)
+	if err != nil {
+		t.Errorf("Write failed: %v", err)
+	}
+}
+
+// createTestFile creates a test file for testing purposes.
+func createTestFile(t *testing.T, fileName string) *os.File {
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatalf("Error creating test file '%s': %v", fileName, err)
+	}
+	return file
+}
+
+// TestWalClean tests the Clean method of Wal.
+func TestWalClean(t *testing.T) {
+	fileName := "test.wal"
+	wal := &Wal{
+		file: createTestFile(t, fileName),
+	}
+
+	defer func() {
+		if err := wal.file.Close(); err != nil {
+			t.Errorf("Error closing test file '%s': %v", fileName, err)
+		}
+		if err := os.Remove(fileName); err != nil {
+			t.Errorf("Error removing test file '%s': %v", fileName, err)
+		}
+	}()
+
+	for j := 0; j < 100; j++ {
+		op := []byte("operation1")
+		err := wal.Write(op)
+		if err != nil {
+			t.Errorf("Write failed - Iteration %d: %v", j, err)
+		}
+	}
+
+	err := wal.Clean()
+	if err != nil {
+		t.Errorf("Clean failed: %v", err)
+	}
+
+	fileInfo, err := os.Stat(wal.file.Name())
+	if err != nil {
+		t.Errorf("Error getting Wal File information: %v", err)
+	}
+
+	if fileSize := fileInfo.Size(); fileSize > 0 {
+		t.Errorf("Error, Wal not truncated. New Size after Cleaning: %d", fileSize)
+	}
+}
+
+// TestWalRecordFormats tests that the set and deletion records of the first format are recovered along with
+// the framed records, and that a framed record holds values longer than 65535 bytes.
+func TestWalRecordFormats(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.wal")
+	large := strings.Repeat("v", 70000)
+	legacy := func(s string) []byte { return append(binary.LittleEndian.AppendUint16(nil, uint16(len(s))), s...) }
+	data := append([]byte{walSet}, append(legacy("set"), legacy("value")...)...)
+	data = append(data, append([]byte{walSet}, append(legacy("deleted"), legacy("value")...)...)...)
+	data = append(data, append([]byte{walDel}, legacy("deleted")...)...)
+	data = append(data, encodeBatch([]Pair{{marker: true, key: "large", value: large, seq: 10}})...)
+	if err := os.WriteFile(fileName, data, FilePermission); err != nil {
+		t.Fatalf("Error writing test file: %v", err)
+	}
+
+	mem, seq, err := Recover(fileName)
+	if err != nil {
+		t.Fatalf("Error recovering WAL: %v", err)
+	}
+	if seq != 10 {
+		t.Errorf("Expected the last sequence number to be 10, got %d", seq)
+	}
+	if v, err := mem.Get("set"); err != nil || v != "value" {
+		t.Errorf("Expected the set record to be recovered, got %s (%v)", v, err)
+	}
+	if _, err := mem.Get("deleted"); !errors.Is(err, ErrKeyDeleted) {
+		t.Errorf("Expected the deletion record to be recovered, got %v", err)
+	}
+	if v, err := mem.Get("large"); err != nil || v != large {
+		t.Errorf("Expected the large value to be recovered, got %d bytes (%v)", len(v), err)
+	}
+}
+
+// TestWALRecoveryModes tests that each recovery mode drops, skips or refuses the torn and corrupt records.
+func TestWALRecoveryModes(t *testing.T) {
+	var records [][]byte
+	for i := 1; i <= 3; i++ {
+		records = append(records, encodeBatch([]Pair{{marker: true, key: fmt.Sprintf("key%d", i), value: "value", seq: uint64(i)}}))
+	}
+	corrupt := func(record []byte) []byte {
+		record = bytes.Clone(record)
+		record[len(record)-1] ^= 0xff
+		return record
+	}
+	// A corrupt record whose value holds a valid record, which must not be replayed as a write of its own.
+	nested := encodeBatch([]Pair{{marker: true, key: "key2", value: string(encodeBatch([]Pair{{marker: true, key: "phantom", value: "value", seq: 9}})), seq: 2}})
+	nested[1] ^= 0xff
+	tests := []struct {
+		name    string
+		records [][]byte
+		mode    WALRecoveryMode
+		err     error // Error expected from the recovery
+		keys    int   // Number of keys recovered
+	}{
+		{"torn tail", [][]byte{records[0], records[1], records[2][:10]}, TolerateCorruptTailRecovery, nil, 2},
+		{"corrupt tail", [][]byte{records[0], records[1], corrupt(records[2])}, TolerateCorruptTailRecovery, nil, 2},
+		{"corrupt record", [][]byte{records[0], corrupt(records[1]), records[2]}, TolerateCorruptTailRecovery, ErrCorruptFile, 0},
+		{"skipped record", [][]byte{records[0], corrupt(records[1]), records[2]}, SkipCorruptRecordsRecovery, nil, 2},
+		{"corrupt tail holding a record", [][]byte{records[0], nested}, TolerateCorruptTailRecovery, nil, 1},
+		{"skipped record holding a record", [][]byte{records[0], nested, records[2]}, SkipCorruptRecordsRecovery, nil, 2},
+		{"skipped tail", [][]byte{records[0], records[1], records[2][:10]}, SkipCorruptRecordsRecovery, nil, 2},
+		{"absolute torn tail", [][]byte{records[0], records[1], records[2][:10]}, AbsoluteConsistencyRecovery, io.ErrUnexpectedEOF, 0},
+		{"absolute", records, AbsoluteConsistencyRecovery, nil, 3},
+	}
+	for _, tt := range tests {
+		walPath := filepath.Join(t.TempDir(), DefaultWALName)
+		if err := os.WriteFile(walPath, bytes.Join(tt.records, nil), FilePermission); err != nil {
+			t.Fatalf("Error writing test file: %v", err)
+		}
+		mem, _, err := recoverWAL(walPath, NewMemTable(), tt.mode)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error recovering: %v", tt.name, err)
+			continue
+		}
+		if keys := mem.table.Len(); keys != tt.keys {
+			t.Errorf("%s: expected %d keys, got %d", tt.name, tt.keys, keys)
+		}
+		// The dropped tail is truncated, so the WAL now recovers in any mode, unless a record was skipped before it.
+		if _, _, err := recoverWAL(walPath, NewMemTable(), AbsoluteConsistencyRecovery); err != nil && tt.mode != SkipCorruptRecordsRecovery {
+			t.Errorf("%s: expected the WAL to be truncated, got %v", tt.name, err)
+		}
+	}
+
+	dir := t.TempDir()
+	torn := append(bytes.Clone(records[0]), records[1][:10]...)
+	if err := os.WriteFile(filepath.Join(dir, DefaultWALName), torn, FilePermission); err != nil {
+		t.Fatalf("Error writing test file: %v", err)
+	}
+	if _, err := Open(dir, &Options{WALRecoveryMode: AbsoluteConsistencyRecovery}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected the torn WAL to be refused, got %v", err)
+	}
+	lstm, err := Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Error opening Lstm: %v", err)
+	}
+	defer lstm.Close()
+	if v, err := lstm.Get([]byte("key1")); err != nil || string(v) != "value" {
+		t.Errorf("Expected the valid record to be recovered, got %s (%v)", v, err)
+	}
+}